@@ -0,0 +1,173 @@
+package broker
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"lilyChat/internal/modules/webSocket/hub"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxNotifyPayload stays under Postgres' 8000 byte NOTIFY limit.
+	maxNotifyPayload = 7900
+	// refPrefix marks a notification that only carries the ID of a
+	// hub_events row holding the actual event.
+	refPrefix = "ref:"
+	// eventRetention is how long oversized events are kept for listeners
+	// to fetch; expired ones are pruned every pruneInterval.
+	eventRetention = time.Minute
+	pruneInterval  = time.Minute
+
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+const notify = `SELECT pg_notify($1, $2);`
+
+const insertHubEvent = `
+INSERT INTO hub_events (payload, created_at)
+VALUES ($1, $2)
+RETURNING id;
+`
+
+const selectHubEvent = `
+SELECT payload FROM hub_events
+WHERE id = $1;
+`
+
+const deleteOldHubEvents = `
+DELETE FROM hub_events
+WHERE created_at < $1;
+`
+
+// PostgresBroker relays hub events between instances sharing a database
+// with LISTEN/NOTIFY. Delivery is best effort: events published while a
+// listener is reconnecting are lost, and clients catch up on messages
+// through the reconnect replay.
+type PostgresBroker struct {
+	sqlDB    *sql.DB
+	channel  string
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+func NewPostgresBroker(sqlDB *sql.DB, dsn, channel string) *PostgresBroker {
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("[Broker] listener event %d: %v", ev, err)
+			}
+		})
+
+	return &PostgresBroker{
+		sqlDB:    sqlDB,
+		channel:  channel,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+}
+
+func (b *PostgresBroker) Publish(event hub.BrokerEvent) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	payload := string(raw)
+	if len(payload) > maxNotifyPayload {
+		payload, err = b.storeEvent(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = b.sqlDB.Exec(notify, b.channel, payload)
+	return err
+}
+
+// storeEvent parks an oversized event in hub_events and returns the
+// notification payload referencing it.
+func (b *PostgresBroker) storeEvent(payload string) (string, error) {
+	now := time.Now()
+
+	var id int64
+	if err := b.sqlDB.QueryRow(insertHubEvent, payload, now.Unix()).Scan(&id); err != nil {
+		return "", err
+	}
+
+	return refPrefix + strconv.FormatInt(id, 10), nil
+}
+
+func (b *PostgresBroker) Subscribe(deliver func(hub.BrokerEvent)) error {
+	if err := b.listener.Listen(b.channel); err != nil {
+		return err
+	}
+
+	go func() {
+		for n := range b.listener.Notify {
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				continue
+			}
+
+			event, err := b.decode(n.Extra)
+			if err != nil {
+				log.Printf("[Broker] cannot decode event: %v", err)
+				continue
+			}
+			deliver(event)
+		}
+	}()
+
+	go b.pruneEvents()
+	return nil
+}
+
+// pruneEvents deletes expired hub_events rows until the broker is closed.
+func (b *PostgresBroker) pruneEvents() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if _, err := b.sqlDB.Exec(deleteOldHubEvents, now.Add(-eventRetention).Unix()); err != nil {
+				log.Printf("[Broker] cannot prune hub events: %v", err)
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *PostgresBroker) decode(payload string) (hub.BrokerEvent, error) {
+	var event hub.BrokerEvent
+
+	if ref, ok := strings.CutPrefix(payload, refPrefix); ok {
+		id, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return event, err
+		}
+		if err := b.sqlDB.QueryRow(selectHubEvent, id).Scan(&payload); err != nil {
+			return event, err
+		}
+	}
+
+	// UseNumber keeps IDs exact when the event is encoded again for clients.
+	dec := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	dec.UseNumber()
+	err := dec.Decode(&event)
+	return event, err
+}
+
+func (b *PostgresBroker) Close() error {
+	close(b.done)
+	return b.listener.Close()
+}
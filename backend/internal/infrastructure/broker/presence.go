@@ -0,0 +1,127 @@
+package broker
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// presenceTTL is how long an instance's entries count without a heartbeat;
+// it spans three of the hub's heartbeat periods.
+const presenceTTL = 90 * time.Second
+
+const lockPresenceUser = `SELECT pg_advisory_xact_lock(hashtext('hub_presence'), $1);`
+
+const upsertPresence = `
+INSERT INTO hub_presence (instance_id, user_id, heartbeat_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (instance_id, user_id) DO UPDATE SET heartbeat_at = EXCLUDED.heartbeat_at;
+`
+
+const deletePresence = `
+DELETE FROM hub_presence
+WHERE instance_id = $1 AND user_id = $2;
+`
+
+const selectPresentElsewhere = `
+SELECT EXISTS (
+    SELECT 1 FROM hub_presence
+    WHERE user_id = $1 AND instance_id <> $2 AND heartbeat_at > $3
+);
+`
+
+const selectOnlineUsers = `
+SELECT DISTINCT user_id FROM hub_presence
+WHERE user_id = ANY($1) AND heartbeat_at > $2;
+`
+
+const refreshPresence = `
+UPDATE hub_presence SET heartbeat_at = $2
+WHERE instance_id = $1;
+`
+
+const deleteStalePresence = `
+DELETE FROM hub_presence
+WHERE heartbeat_at <= $1;
+`
+
+// PostgresPresence keeps the hub's online state in the hub_presence table
+// shared by all instances. Adds and removes for one user are serialized
+// with an advisory lock, so two instances cannot both miss the user's
+// first or last connection. An instance that dies without cleaning up
+// keeps its users online until presenceTTL runs out.
+type PostgresPresence struct {
+	sqlDB *sql.DB
+}
+
+func NewPostgresPresence(sqlDB *sql.DB) *PostgresPresence {
+	return &PostgresPresence{
+		sqlDB: sqlDB,
+	}
+}
+
+func (p *PostgresPresence) Add(instanceID string, userID int64) (bool, error) {
+	elsewhere, err := p.update(upsertPresence, instanceID, userID, time.Now().Unix())
+	return !elsewhere, err
+}
+
+func (p *PostgresPresence) Remove(instanceID string, userID int64) (bool, error) {
+	elsewhere, err := p.update(deletePresence, instanceID, userID)
+	return !elsewhere, err
+}
+
+// update runs query for userID under the user's lock and reports whether
+// the user is still connected to another live instance.
+func (p *PostgresPresence) update(query, instanceID string, userID int64, args ...interface{}) (bool, error) {
+	tx, err := p.sqlDB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(lockPresenceUser, userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(query, append([]interface{}{instanceID, userID}, args...)...); err != nil {
+		return false, err
+	}
+
+	var elsewhere bool
+	err = tx.QueryRow(selectPresentElsewhere, userID, instanceID, staleBefore()).Scan(&elsewhere)
+	if err != nil {
+		return false, err
+	}
+
+	return elsewhere, tx.Commit()
+}
+
+func (p *PostgresPresence) Online(userIDs []int64) (map[int64]bool, error) {
+	rows, err := p.sqlDB.Query(selectOnlineUsers, pq.Array(userIDs), staleBefore())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	online := make(map[int64]bool, len(userIDs))
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		online[userID] = true
+	}
+	return online, rows.Err()
+}
+
+func (p *PostgresPresence) Heartbeat(instanceID string) error {
+	if _, err := p.sqlDB.Exec(refreshPresence, instanceID, time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := p.sqlDB.Exec(deleteStalePresence, staleBefore())
+	return err
+}
+
+func staleBefore() int64 {
+	return time.Now().Add(-presenceTTL).Unix()
+}
@@ -1,7 +1,10 @@
 package components
 
 import (
+	"log"
+
 	"lilyChat/internal/infrastructure/config"
+	"lilyChat/internal/infrastructure/storage"
 	"lilyChat/internal/infrastructure/utils"
 	"lilyChat/internal/modules/webSocket/hub"
 )
@@ -11,13 +14,20 @@ type Components struct {
 	JWT         utils.JTW
 	WSHub       *hub.Hub
 	Logger 		utils.Logger
+	Storage     storage.FileStorage
 }
 
-func NewComponents(cfg config.Config, jwt utils.JTW, log utils.Logger) *Components {
+func NewComponents(cfg config.Config, jwt utils.JTW, logger utils.Logger) *Components {
+	fileStorage, err := storage.NewLocalStorage(cfg.Storage.Path)
+	if err != nil {
+		log.Fatalf("[Storage] cannot init local storage: %v", err)
+	}
+
 	return &Components{
 		Conf:        cfg,
 		JWT:         jwt,
 		WSHub:       hub.NewHub(),
-		Logger: 	 log,
+		Logger: 	 logger,
+		Storage:     fileStorage,
 	}
 }
@@ -28,10 +28,48 @@ type JWTConfig struct {
 
 type ServerConfig struct {
 	Port                 string        `yaml:"port"`
+	// DebugAddr is where the internal listener serving /debug/vars binds.
+	// It must not be reachable from outside; the default is loopback only.
+	DebugAddr            string        `yaml:"debug_addr"`
 	ShutdownTimeout      time.Duration `yaml:"-"`
 	RawShutdownTimeout   string        `yaml:"shutdown_timeout"`
 }
 
+type ChatConfig struct {
+	DeleteWindow     time.Duration `yaml:"-"`
+	RawDeleteWindow  string        `yaml:"delete_window"`
+	MaxMessageLength int           `yaml:"max_message_length"`
+	MaxFrameSize     int64         `yaml:"max_frame_size"`
+}
+
+type StorageConfig struct {
+	Path          string `yaml:"path"`
+	MaxUploadSize int64  `yaml:"max_upload_size"`
+}
+
+// RateLimitConfig limits WebSocket frames per user: Rate frames per second
+// on average with bursts of up to Burst. A user rejected MaxViolations times
+// within ViolationWindow is disconnected everywhere and may not reconnect
+// for Penalty.
+type RateLimitConfig struct {
+	Rate               float64       `yaml:"rate"`
+	Burst              int           `yaml:"burst"`
+	MaxViolations      int           `yaml:"max_violations"`
+	ViolationWindow    time.Duration `yaml:"-"`
+	RawViolationWindow string        `yaml:"violation_window"`
+	Penalty            time.Duration `yaml:"-"`
+	RawPenalty         string        `yaml:"penalty"`
+}
+
+// BrokerConfig picks how hub events reach other server instances: "local"
+// keeps them in process (a single instance), "postgres" relays them with
+// LISTEN/NOTIFY on Channel and shares online state in the hub_presence
+// table.
+type BrokerConfig struct {
+	Driver  string `yaml:"driver"`
+	Channel string `yaml:"channel"`
+}
+
 type FrontendConfig struct {
 	Port string `yaml:"port"`
 }
@@ -41,6 +79,10 @@ type Config struct {
 	JWT      JWTConfig      `yaml:"jwt"`
 	Server   ServerConfig   `yaml:"server"`
 	Frontend FrontendConfig `yaml:"frontend"`
+	Chat     ChatConfig     `yaml:"chat"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Broker   BrokerConfig   `yaml:"broker"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
 	PostgresDSN string `yaml:"-"`
 }
 
@@ -81,6 +123,56 @@ func LoadConfig(path string) *Config {
 		cfg.Server.ShutdownTimeout = shutdownTimeout
 	}
 
+	if cfg.Server.DebugAddr == "" {
+		cfg.Server.DebugAddr = "127.0.0.1:9901"
+	}
+
+	deleteWindow, err := time.ParseDuration(cfg.Chat.RawDeleteWindow)
+	if err != nil {
+		deleteWindow = 1 * time.Hour
+	}
+	cfg.Chat.DeleteWindow = deleteWindow
+	if cfg.Chat.MaxMessageLength <= 0 {
+		cfg.Chat.MaxMessageLength = 4000
+	}
+	if cfg.Chat.MaxFrameSize <= 0 {
+		cfg.Chat.MaxFrameSize = 64 << 10
+	}
+
+	if cfg.Storage.Path == "" {
+		cfg.Storage.Path = "uploads"
+	}
+	if cfg.Storage.MaxUploadSize <= 0 {
+		cfg.Storage.MaxUploadSize = 10 << 20
+	}
+
+	if cfg.RateLimit.Rate <= 0 {
+		cfg.RateLimit.Rate = 5
+	}
+	if cfg.RateLimit.Burst <= 0 {
+		cfg.RateLimit.Burst = 20
+	}
+	if cfg.RateLimit.MaxViolations <= 0 {
+		cfg.RateLimit.MaxViolations = 20
+	}
+	violationWindow, err := time.ParseDuration(cfg.RateLimit.RawViolationWindow)
+	if err != nil {
+		violationWindow = 1 * time.Minute
+	}
+	cfg.RateLimit.ViolationWindow = violationWindow
+	penalty, err := time.ParseDuration(cfg.RateLimit.RawPenalty)
+	if err != nil {
+		penalty = 1 * time.Minute
+	}
+	cfg.RateLimit.Penalty = penalty
+
+	if cfg.Broker.Driver == "" {
+		cfg.Broker.Driver = "local"
+	}
+	if cfg.Broker.Channel == "" {
+		cfg.Broker.Channel = "lilichat_hub"
+	}
+
 	cfg.PostgresDSN = fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
 		cfg.Database.User,
 		cfg.Database.Password,
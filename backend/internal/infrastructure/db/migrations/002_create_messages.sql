@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS messages (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    receiver_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_sender_receiver_created
+    ON messages (sender_id, receiver_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_messages_receiver_sender_created
+    ON messages (receiver_id, sender_id, created_at);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS read_at BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_messages_receiver_unread
+    ON messages (receiver_id, sender_id)
+    WHERE read_at IS NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at BIGINT;
\ No newline at end of file
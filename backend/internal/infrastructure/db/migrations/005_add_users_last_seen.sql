@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen BIGINT;
\ No newline at end of file
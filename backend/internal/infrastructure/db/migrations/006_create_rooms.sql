@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS rooms (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room_id BIGINT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at BIGINT NOT NULL,
+    PRIMARY KEY (room_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_members_user
+    ON room_members (user_id);
+
+-- Room messages have a room_id and no receiver_id.
+ALTER TABLE messages ALTER COLUMN receiver_id DROP NOT NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS room_id BIGINT REFERENCES rooms(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_messages_room
+    ON messages (room_id, id)
+    WHERE room_id IS NOT NULL;
\ No newline at end of file
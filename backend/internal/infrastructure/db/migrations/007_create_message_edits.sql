@@ -0,0 +1,12 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at BIGINT;
+
+-- Each row keeps the text a message had before an edit replaced it.
+CREATE TABLE IF NOT EXISTS message_edits (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    edited_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message
+    ON message_edits (message_id, id);
\ No newline at end of file
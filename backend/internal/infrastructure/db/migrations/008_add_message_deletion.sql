@@ -0,0 +1,9 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at BIGINT;
+
+-- Per-user tombstones for messages hidden with "delete for me".
+CREATE TABLE IF NOT EXISTS message_hidden (
+    message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hidden_at BIGINT NOT NULL,
+    PRIMARY KEY (message_id, user_id)
+);
\ No newline at end of file
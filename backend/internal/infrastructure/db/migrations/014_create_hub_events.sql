@@ -0,0 +1,9 @@
+-- Holds broker events too large for a NOTIFY payload; rows are only needed
+-- for a few seconds, so the table is not WAL-logged.
+CREATE UNLOGGED TABLE IF NOT EXISTS hub_events (
+    id BIGSERIAL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_hub_events_created ON hub_events (created_at);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS contact_requests (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    receiver_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at BIGINT NOT NULL,
+    responded_at BIGINT
+);
+
+-- At most one open request per pair of users, whichever way it goes.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contact_requests_pending_pair
+    ON contact_requests (LEAST(sender_id, receiver_id), GREATEST(sender_id, receiver_id))
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_contact_requests_receiver
+    ON contact_requests (receiver_id, status);
+
+CREATE INDEX IF NOT EXISTS idx_contact_requests_sender
+    ON contact_requests (sender_id, status);
+
+-- Contacts are stored once per direction so each user's list is one lookup.
+CREATE TABLE IF NOT EXISTS contacts (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, contact_id)
+);
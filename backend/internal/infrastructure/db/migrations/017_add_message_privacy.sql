@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS message_privacy TEXT NOT NULL DEFAULT 'everyone';
+
+-- is_request marks messages waiting in the receiver's message-requests inbox;
+-- the receiver does not see them in their conversations until accepted.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_request BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_messages_requests
+    ON messages (receiver_id, sender_id)
+    WHERE is_request;
+
+CREATE TABLE IF NOT EXISTS message_requests (
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    receiver_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at BIGINT NOT NULL,
+    responded_at BIGINT,
+    PRIMARY KEY (sender_id, receiver_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_requests_receiver
+    ON message_requests (receiver_id, status);
@@ -0,0 +1,29 @@
+-- A mute silences notifications for one direct conversation (peer_id) or
+-- room (room_id) until muted_until, or indefinitely when it is NULL.
+CREATE TABLE IF NOT EXISTS conversation_mutes (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    peer_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    room_id BIGINT REFERENCES rooms(id) ON DELETE CASCADE,
+    muted_until BIGINT,
+    created_at BIGINT NOT NULL,
+    CHECK ((peer_id IS NULL) <> (room_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_mutes_peer
+    ON conversation_mutes (user_id, peer_id)
+    WHERE peer_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_mutes_room
+    ON conversation_mutes (user_id, room_id)
+    WHERE room_id IS NOT NULL;
+
+-- start_time and end_time are "HH:MM" wall clock times in timezone, an IANA
+-- zone name. A window whose end is before its start runs past midnight.
+CREATE TABLE IF NOT EXISTS do_not_disturb (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    start_time TEXT NOT NULL,
+    end_time TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    updated_at BIGINT NOT NULL
+);
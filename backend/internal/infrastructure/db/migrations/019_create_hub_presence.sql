@@ -0,0 +1,11 @@
+-- One row per user per server instance holding at least one of the user's
+-- connections. Instances refresh heartbeat_at periodically; rows of an
+-- instance that stopped doing so are ignored and eventually purged.
+CREATE UNLOGGED TABLE IF NOT EXISTS hub_presence (
+    instance_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    heartbeat_at BIGINT NOT NULL,
+    PRIMARY KEY (instance_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_hub_presence_user ON hub_presence (user_id);
@@ -65,7 +65,9 @@ func (r *PostgresRepo) Update(table string, filters map[string]interface{}, upda
 	setClause, setArgs := sqlutil.BuildUpdateClause(updates, len(whereArgs)+1)
 
 	query := fmt.Sprintf(queries.UpdateRecord, table, setClause, where)
-	args := append(setArgs, whereArgs...)
+	// The WHERE placeholders are numbered from $1 and the SET ones follow
+	// them, so the arguments must come in the same order.
+	args := append(whereArgs, setArgs...)
 
 	_, err := r.DB.Exec(query, args...)
 	return err
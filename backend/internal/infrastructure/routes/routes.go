@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"expvar"
 	"lilyChat/internal/infrastructure/components"
 	"lilyChat/internal/infrastructure/middleware"
 	"lilyChat/internal/modules"
@@ -33,6 +34,85 @@ func NewApiRouter(controllers *modules.Controller, components *components.Compon
 			r.Get("/{username}", controllers.Users.GetUserByUsername)
 		})
 
+		r.Route("/settings", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/privacy", controllers.Users.GetPrivacy)
+			r.Put("/privacy", controllers.Users.UpdatePrivacy)
+			r.Get("/dnd", controllers.Notifications.GetDoNotDisturb)
+			r.Put("/dnd", controllers.Notifications.UpdateDoNotDisturb)
+		})
+
+		r.Route("/presence", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/", controllers.Users.GetPresence)
+		})
+
+		r.Route("/messages", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/search", controllers.Messages.Search)
+			r.Get("/{userId}", controllers.Messages.GetHistory)
+			r.Patch("/{messageId}", controllers.Messages.EditMessage)
+			r.Delete("/{messageId}", controllers.Messages.DeleteMessage)
+			r.Get("/{messageId}/edits", controllers.Messages.GetRevisions)
+			r.Post("/{messageId}/reactions", controllers.Messages.AddReaction)
+			r.Delete("/{messageId}/reactions", controllers.Messages.RemoveReaction)
+		})
+
+		r.Route("/conversations", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/", controllers.Messages.GetInbox)
+		})
+
+		r.Route("/mutes", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/", controllers.Notifications.ListMutes)
+			r.Post("/", controllers.Notifications.Mute)
+			r.Delete("/users/{userId}", controllers.Notifications.UnmuteUser)
+			r.Delete("/rooms/{roomId}", controllers.Notifications.UnmuteRoom)
+		})
+
+		r.Route("/message-requests", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/", controllers.MessageRequests.ListMessageRequests)
+			r.Get("/{userId}/messages", controllers.MessageRequests.GetRequestMessages)
+			r.Post("/{userId}/accept", controllers.MessageRequests.AcceptMessageRequest)
+			r.Post("/{userId}/reject", controllers.MessageRequests.RejectMessageRequest)
+		})
+
+		r.Route("/rooms", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Post("/", controllers.Rooms.CreateRoom)
+			r.Get("/", controllers.Rooms.ListRooms)
+			r.Get("/{roomId}", controllers.Rooms.GetRoom)
+			r.Post("/{roomId}/members", controllers.Rooms.AddMember)
+			r.Delete("/{roomId}/members/{userId}", controllers.Rooms.RemoveMember)
+			r.Get("/{roomId}/messages", controllers.Rooms.GetHistory)
+		})
+
+		r.Route("/attachments", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Post("/", controllers.Attachments.Upload)
+			r.Get("/{attachmentId}", controllers.Attachments.Download)
+		})
+
+		r.Route("/blocks", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/", controllers.Blocks.List)
+			r.Post("/", controllers.Blocks.Block)
+			r.Delete("/{userId}", controllers.Blocks.Unblock)
+		})
+
+		r.Route("/contacts", func(r chi.Router) {
+			r.Use(authCheck)
+			r.Get("/", controllers.Contacts.ListContacts)
+			r.Delete("/{userId}", controllers.Contacts.RemoveContact)
+			r.Get("/requests", controllers.Contacts.ListRequests)
+			r.Post("/requests", controllers.Contacts.SendRequest)
+			r.Post("/requests/{requestId}/accept", controllers.Contacts.AcceptRequest)
+			r.Post("/requests/{requestId}/decline", controllers.Contacts.DeclineRequest)
+			r.Delete("/requests/{requestId}", controllers.Contacts.CancelRequest)
+		})
+
 		r.Route("/ws", func(r chi.Router) {
 			r.Use(authCheck)
 			r.Get("/", controllers.Chat)
@@ -61,3 +141,11 @@ func NewRouter(controllers *modules.Controller, components *components.Component
 
 	return r
 }
+
+// NewDebugRouter serves process internals such as expvar. It is mounted on
+// the internal debug listener only, never next to /api.
+func NewDebugRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Handle("/debug/vars", expvar.Handler())
+	return r
+}
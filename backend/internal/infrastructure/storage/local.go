@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage stores files on the local filesystem below root.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+func (s *LocalStorage) Save(key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path maps key to a file below root, refusing keys that would escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, clean), nil
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// FileStorage keeps uploaded file contents under opaque keys. Metadata lives
+// in the database; implementations only deal with bytes.
+type FileStorage interface {
+	// Save writes everything from r under key and returns the number of
+	// bytes stored.
+	Save(key string, r io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	attachmentRepo "lilyChat/internal/modules/attachments/repository"
+	"lilyChat/internal/modules/attachments/service"
+	dto "lilyChat/internal/modules/dto"
+)
+
+// multipartMemory is how much of an upload is buffered in memory before
+// the rest spills to a temporary file.
+const multipartMemory = 8 << 20
+
+type AttachmentsController interface {
+	Upload(w http.ResponseWriter, r *http.Request)
+	Download(w http.ResponseWriter, r *http.Request)
+}
+
+type AttachmentController struct {
+	attachmentService service.AttachmentServicer
+	maxUploadSize     int64
+}
+
+func NewAttachmentController(service service.AttachmentServicer, components *components.Components) *AttachmentController {
+	return &AttachmentController{
+		attachmentService: service,
+		maxUploadSize:     components.Conf.Storage.MaxUploadSize,
+	}
+}
+
+// Upload accepts one or more files in the "file" field of a multipart form
+// and returns their metadata. The returned IDs can then be referenced from
+// a message's attachment_ids.
+func (c *AttachmentController) Upload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, c.maxUploadSize+multipartMemory)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		http.Error(w, "invalid or too large upload", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+
+	attachments := make([]*dto.Attachment, 0, len(files))
+	for _, fh := range files {
+		if fh.Size > c.maxUploadSize {
+			http.Error(w, "file too large: "+fh.Filename, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		attachment, err := c.attachmentService.Upload(userID, fh.Filename, f)
+		f.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+func (c *AttachmentController) Download(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	attachmentID, err := dto.PathID(r, "attachmentId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	attachment, body, err := c.attachmentService.Open(userID, attachmentID)
+	switch {
+	case errors.Is(err, service.ErrAccessDenied), errors.Is(err, attachmentRepo.ErrAttachmentNotFound):
+		// Do not reveal whether an attachment the caller cannot see exists.
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, body)
+}
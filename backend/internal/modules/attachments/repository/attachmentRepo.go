@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	dto "lilyChat/internal/modules/dto"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+const insertAttachment = `
+INSERT INTO attachments (owner_id, name, size, mime_type, checksum, storage_key, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id;
+`
+
+const selectAttachment = `
+SELECT id, owner_id, message_id, name, size, mime_type, checksum, storage_key, created_at
+FROM attachments
+WHERE id = $1;
+`
+
+// selectCanAccessAttachment lets the uploader through, and once the file is
+// attached to a message that still exists, everyone who can see the message.
+const selectCanAccessAttachment = `
+SELECT EXISTS (
+    SELECT 1
+    FROM attachments a
+    LEFT JOIN messages m ON m.id = a.message_id AND m.deleted_at IS NULL
+    WHERE a.id = $1
+      AND (
+          a.owner_id = $2
+          OR m.sender_id = $2
+          OR m.receiver_id = $2
+          OR EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = $2)
+      )
+);
+`
+
+type AttachmentRepositorier interface {
+	Create(attachment *dto.Attachment) error
+	Get(attachmentID int64) (*dto.Attachment, error)
+	CanAccess(attachmentID, userID int64) (bool, error)
+}
+
+type AttachmentRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewAttachmentRepo(sqlDB *sql.DB) *AttachmentRepo {
+	return &AttachmentRepo{
+		sqlDB: sqlDB,
+	}
+}
+
+func (a *AttachmentRepo) Create(attachment *dto.Attachment) error {
+	return a.sqlDB.QueryRow(insertAttachment,
+		attachment.OwnerID,
+		attachment.Name,
+		attachment.Size,
+		attachment.MimeType,
+		attachment.Checksum,
+		attachment.StorageKey,
+		attachment.CreatedAt,
+	).Scan(&attachment.ID)
+}
+
+func (a *AttachmentRepo) Get(attachmentID int64) (*dto.Attachment, error) {
+	var (
+		attachment = &dto.Attachment{}
+		messageID  sql.NullInt64
+	)
+	err := a.sqlDB.QueryRow(selectAttachment, attachmentID).Scan(
+		&attachment.ID,
+		&attachment.OwnerID,
+		&messageID,
+		&attachment.Name,
+		&attachment.Size,
+		&attachment.MimeType,
+		&attachment.Checksum,
+		&attachment.StorageKey,
+		&attachment.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	attachment.MessageID = messageID.Int64
+	return attachment, nil
+}
+
+func (a *AttachmentRepo) CanAccess(attachmentID, userID int64) (bool, error) {
+	var ok bool
+	err := a.sqlDB.QueryRow(selectCanAccessAttachment, attachmentID, userID).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/storage"
+	attachmentRepo "lilyChat/internal/modules/attachments/repository"
+	dto "lilyChat/internal/modules/dto"
+)
+
+const maxFileNameLength = 255
+
+var ErrAccessDenied = errors.New("you cannot access this attachment")
+
+type AttachmentServicer interface {
+	Upload(ownerID int64, name string, r io.Reader) (*dto.Attachment, error)
+	Open(userID, attachmentID int64) (*dto.Attachment, io.ReadCloser, error)
+}
+
+type AttachmentService struct {
+	attachmentRepo attachmentRepo.AttachmentRepositorier
+	storage        storage.FileStorage
+}
+
+func NewAttachmentService(repo attachmentRepo.AttachmentRepositorier, components *components.Components) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepo: repo,
+		storage:        components.Storage,
+	}
+}
+
+// Upload stores the file read from r and records its metadata. The MIME
+// type is sniffed from the content rather than trusted from the client.
+func (s *AttachmentService) Upload(ownerID int64, name string, r io.Reader) (*dto.Attachment, error) {
+	name = sanitizeFileName(name)
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	key, err := newStorageKey(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	size, err := s.storage.Save(key, io.TeeReader(io.MultiReader(bytes.NewReader(head), r), hash))
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &dto.Attachment{
+		OwnerID:    ownerID,
+		Name:       name,
+		Size:       size,
+		MimeType:   http.DetectContentType(head),
+		Checksum:   hex.EncodeToString(hash.Sum(nil)),
+		StorageKey: key,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := s.attachmentRepo.Create(attachment); err != nil {
+		s.storage.Delete(key)
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// Open returns the attachment and its contents if userID may download it.
+// The caller must close the returned reader.
+func (s *AttachmentService) Open(userID, attachmentID int64) (*dto.Attachment, io.ReadCloser, error) {
+	ok, err := s.attachmentRepo.CanAccess(attachmentID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrAccessDenied
+	}
+
+	attachment, err := s.attachmentRepo.Get(attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := s.storage.Open(attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, body, nil
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == "/" || name == "" || !utf8.ValidString(name) {
+		return "file"
+	}
+
+	runes := []rune(name)
+	if len(runes) > maxFileNameLength {
+		name = string(runes[:maxFileNameLength])
+	}
+	return name
+}
+
+func newStorageKey(ownerID int64) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%s", ownerID, hex.EncodeToString(buf)), nil
+}
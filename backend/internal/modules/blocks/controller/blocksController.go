@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	"lilyChat/internal/modules/blocks/service"
+	dto "lilyChat/internal/modules/dto"
+)
+
+type BlocksController interface {
+	Block(w http.ResponseWriter, r *http.Request)
+	Unblock(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+}
+
+type BlockController struct {
+	blocksService service.BlocksServicer
+}
+
+func NewBlockController(service service.BlocksServicer, components *components.Components) *BlockController {
+	return &BlockController{
+		blocksService: service,
+	}
+}
+
+func (c *BlockController) Block(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req dto.BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.blocksService.Block(userID, req.UserID); err != nil {
+		http.Error(w, err.Error(), blockErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "user blocked"})
+}
+
+func (c *BlockController) Unblock(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	blockedID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.blocksService.Unblock(userID, blockedID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "user unblocked"})
+}
+
+// List returns everyone the caller has blocked, most recent first.
+func (c *BlockController) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	blocks, err := c.blocksService.List(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocks)
+}
+
+func blockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrBlockSelf):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	dto "lilyChat/internal/modules/dto"
+)
+
+const insertBlock = `
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+`
+
+// deleteContactsBetween and cancelContactRequestsBetween end what two users
+// had going when one blocks the other.
+const deleteContactsBetween = `
+DELETE FROM contacts
+WHERE (user_id = $1 AND contact_id = $2) OR (user_id = $2 AND contact_id = $1);
+`
+
+const cancelContactRequestsBetween = `
+UPDATE contact_requests
+SET status = $3, responded_at = $4
+WHERE status = 'pending'
+  AND ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1));
+`
+
+const deleteBlock = `
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+`
+
+const selectBlocks = `
+SELECT u.id, u.username, b.created_at
+FROM blocks b
+JOIN users u ON u.id = b.blocked_id
+WHERE b.blocker_id = $1
+ORDER BY b.created_at DESC;
+`
+
+const selectIsBlocked = `
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocker_id = $1 AND blocked_id = $2
+);
+`
+
+const selectIsBlockedEither = `
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+);
+`
+
+const selectBlockedPeers = `
+SELECT blocked_id FROM blocks WHERE blocker_id = $1
+UNION
+SELECT blocker_id FROM blocks WHERE blocked_id = $1;
+`
+
+type BlocksRepositorier interface {
+	// Block and Unblock report whether anything changed. Block also ends
+	// any contact and cancels a pending contact request between the two
+	// users, in the same transaction.
+	Block(blockerID, blockedID, at int64) (bool, error)
+	Unblock(blockerID, blockedID int64) (bool, error)
+	List(blockerID int64) ([]*dto.BlockedUser, error)
+
+	IsBlocked(blockerID, blockedID int64) (bool, error)
+	// IsBlockedEither reports whether either user blocked the other.
+	IsBlockedEither(user1ID, user2ID int64) (bool, error)
+	// GetBlockedPeers lists everyone userID blocked or was blocked by.
+	GetBlockedPeers(userID int64) ([]int64, error)
+}
+
+type BlocksRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewBlocksRepo(sqlDB *sql.DB) *BlocksRepo {
+	return &BlocksRepo{
+		sqlDB: sqlDB,
+	}
+}
+
+func (b *BlocksRepo) Block(blockerID, blockedID, at int64) (bool, error) {
+	tx, err := b.sqlDB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(insertBlock, blockerID, blockedID, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(deleteContactsBetween, blockerID, blockedID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(cancelContactRequestsBetween, blockerID, blockedID, dto.ContactRequestCancelled, at); err != nil {
+		return false, err
+	}
+
+	return n > 0, tx.Commit()
+}
+
+func (b *BlocksRepo) Unblock(blockerID, blockedID int64) (bool, error) {
+	res, err := b.sqlDB.Exec(deleteBlock, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (b *BlocksRepo) List(blockerID int64) ([]*dto.BlockedUser, error) {
+	rows, err := b.sqlDB.Query(selectBlocks, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]*dto.BlockedUser, 0)
+	for rows.Next() {
+		block := &dto.BlockedUser{}
+		if err := rows.Scan(&block.User.ID, &block.User.Username, &block.BlockedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, rows.Err()
+}
+
+func (b *BlocksRepo) IsBlocked(blockerID, blockedID int64) (bool, error) {
+	var blocked bool
+	err := b.sqlDB.QueryRow(selectIsBlocked, blockerID, blockedID).Scan(&blocked)
+	return blocked, err
+}
+
+func (b *BlocksRepo) IsBlockedEither(user1ID, user2ID int64) (bool, error) {
+	var blocked bool
+	err := b.sqlDB.QueryRow(selectIsBlockedEither, user1ID, user2ID).Scan(&blocked)
+	return blocked, err
+}
+
+func (b *BlocksRepo) GetBlockedPeers(userID int64) ([]int64, error) {
+	rows, err := b.sqlDB.Query(selectBlockedPeers, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
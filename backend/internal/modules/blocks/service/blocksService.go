@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	blocksRepo "lilyChat/internal/modules/blocks/repository"
+	dto "lilyChat/internal/modules/dto"
+	usersRepo "lilyChat/internal/modules/users/repository"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrBlockSelf    = errors.New("you cannot block yourself")
+)
+
+type BlocksServicer interface {
+	Block(blockerID, blockedID int64) error
+	Unblock(blockerID, blockedID int64) error
+	List(blockerID int64) ([]*dto.BlockedUser, error)
+}
+
+type BlocksService struct {
+	blocksRepo blocksRepo.BlocksRepositorier
+	usersRepo  usersRepo.UsersRepositorier
+}
+
+func NewBlocksService(blocksRepo blocksRepo.BlocksRepositorier, usersRepo usersRepo.UsersRepositorier) *BlocksService {
+	return &BlocksService{
+		blocksRepo: blocksRepo,
+		usersRepo:  usersRepo,
+	}
+}
+
+// Block stops blockedID from messaging blockerID or seeing their typing and
+// presence, and hides blockerID from blockedID's user search. It also ends
+// their contact and cancels a pending contact request between them.
+// Blocking someone twice is a no-op.
+func (s *BlocksService) Block(blockerID, blockedID int64) error {
+	if blockerID == blockedID {
+		return ErrBlockSelf
+	}
+
+	exists, err := s.usersRepo.Exists(context.Background(), blockedID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	_, err = s.blocksRepo.Block(blockerID, blockedID, time.Now().Unix())
+	return err
+}
+
+func (s *BlocksService) Unblock(blockerID, blockedID int64) error {
+	_, err := s.blocksRepo.Unblock(blockerID, blockedID)
+	return err
+}
+
+func (s *BlocksService) List(blockerID int64) ([]*dto.BlockedUser, error) {
+	return s.blocksRepo.List(blockerID)
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	contactsRepo "lilyChat/internal/modules/contacts/repository"
+	"lilyChat/internal/modules/contacts/service"
+	dto "lilyChat/internal/modules/dto"
+)
+
+type ContactsController interface {
+	ListContacts(w http.ResponseWriter, r *http.Request)
+	RemoveContact(w http.ResponseWriter, r *http.Request)
+	SendRequest(w http.ResponseWriter, r *http.Request)
+	ListRequests(w http.ResponseWriter, r *http.Request)
+	AcceptRequest(w http.ResponseWriter, r *http.Request)
+	DeclineRequest(w http.ResponseWriter, r *http.Request)
+	CancelRequest(w http.ResponseWriter, r *http.Request)
+}
+
+type ContactController struct {
+	contactsService service.ContactsServicer
+}
+
+func NewContactController(service service.ContactsServicer, components *components.Components) *ContactController {
+	return &ContactController{
+		contactsService: service,
+	}
+}
+
+// ListContacts returns the caller's contacts with their online state and
+// last_seen.
+func (c *ContactController) ListContacts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	contacts, err := c.contactsService.ListContacts(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contacts)
+}
+
+func (c *ContactController) RemoveContact(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	contactID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.contactsService.RemoveContact(userID, contactID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "contact removed"})
+}
+
+func (c *ContactController) SendRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req dto.ContactRequestCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	request, err := c.contactsService.SendRequest(userID, req.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), contactErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+// ListRequests returns the caller's pending requests: received ones by
+// default, sent ones with ?direction=outgoing.
+func (c *ContactController) ListRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var incoming bool
+	switch r.URL.Query().Get("direction") {
+	case "", "incoming":
+		incoming = true
+	case "outgoing":
+	default:
+		http.Error(w, "direction must be incoming or outgoing", http.StatusBadRequest)
+		return
+	}
+
+	requests, err := c.contactsService.ListRequests(userID, incoming)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+func (c *ContactController) AcceptRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := dto.PathID(r, "requestId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request, err := c.contactsService.AcceptRequest(userID, requestID)
+	if err != nil {
+		http.Error(w, err.Error(), contactErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+func (c *ContactController) DeclineRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := dto.PathID(r, "requestId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.contactsService.DeclineRequest(userID, requestID); err != nil {
+		http.Error(w, err.Error(), contactErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "contact request declined"})
+}
+
+func (c *ContactController) CancelRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := dto.PathID(r, "requestId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.contactsService.CancelRequest(userID, requestID); err != nil {
+		http.Error(w, err.Error(), contactErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "contact request cancelled"})
+}
+
+func contactErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, contactsRepo.ErrRequestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotRequestParty), errors.Is(err, service.ErrNotRequestSender):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAlreadyContacts), errors.Is(err, contactsRepo.ErrRequestExists):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrSelfRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	dto "lilyChat/internal/modules/dto"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRequestNotFound = errors.New("contact request not found")
+	ErrRequestExists   = errors.New("a contact request between these users is already pending")
+)
+
+const contactRequestColumns = `r.id, r.sender_id, r.receiver_id, r.status, r.created_at, r.responded_at`
+
+const insertContactRequest = `
+INSERT INTO contact_requests (sender_id, receiver_id, status, created_at)
+VALUES ($1, $2, 'pending', $3)
+RETURNING id;
+`
+
+const selectContactRequest = `
+SELECT ` + contactRequestColumns + `
+FROM contact_requests r
+WHERE r.id = $1;
+`
+
+const selectPendingRequestBetween = `
+SELECT ` + contactRequestColumns + `
+FROM contact_requests r
+WHERE r.status = 'pending'
+  AND ((r.sender_id = $1 AND r.receiver_id = $2) OR (r.sender_id = $2 AND r.receiver_id = $1));
+`
+
+const selectIncomingRequests = `
+SELECT ` + contactRequestColumns + `, u.id, u.username
+FROM contact_requests r
+JOIN users u ON u.id = r.sender_id
+WHERE r.receiver_id = $1 AND r.status = 'pending'
+ORDER BY r.id DESC;
+`
+
+const selectOutgoingRequests = `
+SELECT ` + contactRequestColumns + `, u.id, u.username
+FROM contact_requests r
+JOIN users u ON u.id = r.receiver_id
+WHERE r.sender_id = $1 AND r.status = 'pending'
+ORDER BY r.id DESC;
+`
+
+// updateRequestStatus only moves requests out of "pending", so a request is
+// answered at most once.
+const updateRequestStatus = `
+UPDATE contact_requests
+SET status = $2, responded_at = $3
+WHERE id = $1 AND status = 'pending';
+`
+
+const insertContact = `
+INSERT INTO contacts (user_id, contact_id, created_at)
+VALUES ($1, $2, $3), ($2, $1, $3)
+ON CONFLICT (user_id, contact_id) DO NOTHING;
+`
+
+const deleteContact = `
+DELETE FROM contacts
+WHERE (user_id = $1 AND contact_id = $2) OR (user_id = $2 AND contact_id = $1);
+`
+
+const selectAreContacts = `
+SELECT EXISTS (
+    SELECT 1 FROM contacts
+    WHERE user_id = $1 AND contact_id = $2
+);
+`
+
+const selectContacts = `
+SELECT u.id, u.username, c.created_at, u.last_seen
+FROM contacts c
+JOIN users u ON u.id = c.contact_id
+WHERE c.user_id = $1
+ORDER BY u.username;
+`
+
+type ContactsRepositorier interface {
+	// CreateRequest stores a pending request, filling in req.ID. It fails
+	// with ErrRequestExists if one is already pending between the users.
+	CreateRequest(req *dto.ContactRequest) error
+	GetRequest(requestID int64) (*dto.ContactRequest, error)
+	// GetPendingBetween finds the pending request between two users in
+	// either direction.
+	GetPendingBetween(user1ID, user2ID int64) (*dto.ContactRequest, error)
+	ListRequests(userID int64, incoming bool) ([]*dto.ContactRequest, error)
+	// Accept marks a pending request accepted and makes both users
+	// contacts. It fails with ErrRequestNotFound if the request is not
+	// pending anymore.
+	Accept(requestID, at int64) error
+	// SetStatus moves a pending request to status and reports whether it
+	// was still pending.
+	SetStatus(requestID int64, status string, at int64) (bool, error)
+
+	AreContacts(user1ID, user2ID int64) (bool, error)
+	ListContacts(userID int64) ([]*dto.Contact, error)
+	RemoveContact(user1ID, user2ID int64) (bool, error)
+}
+
+type ContactsRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewContactsRepo(sqlDB *sql.DB) *ContactsRepo {
+	return &ContactsRepo{
+		sqlDB: sqlDB,
+	}
+}
+
+func (c *ContactsRepo) CreateRequest(req *dto.ContactRequest) error {
+	err := c.sqlDB.QueryRow(insertContactRequest, req.SenderID, req.ReceiverID, req.CreatedAt).Scan(&req.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrRequestExists
+	}
+	if err != nil {
+		return err
+	}
+	req.Status = dto.ContactRequestPending
+	return nil
+}
+
+func (c *ContactsRepo) GetRequest(requestID int64) (*dto.ContactRequest, error) {
+	return scanRequest(c.sqlDB.QueryRow(selectContactRequest, requestID))
+}
+
+func (c *ContactsRepo) GetPendingBetween(user1ID, user2ID int64) (*dto.ContactRequest, error) {
+	return scanRequest(c.sqlDB.QueryRow(selectPendingRequestBetween, user1ID, user2ID))
+}
+
+func (c *ContactsRepo) ListRequests(userID int64, incoming bool) ([]*dto.ContactRequest, error) {
+	query := selectOutgoingRequests
+	if incoming {
+		query = selectIncomingRequests
+	}
+
+	rows, err := c.sqlDB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make([]*dto.ContactRequest, 0)
+	for rows.Next() {
+		user := &dto.PublicUser{}
+		req, err := scanRequest(rows, &user.ID, &user.Username)
+		if err != nil {
+			return nil, err
+		}
+		req.User = user
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+func (c *ContactsRepo) Accept(requestID, at int64) error {
+	tx, err := c.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(updateRequestStatus, requestID, dto.ContactRequestAccepted, at)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRequestNotFound
+	}
+
+	req, err := scanRequest(tx.QueryRow(selectContactRequest, requestID))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(insertContact, req.SenderID, req.ReceiverID, at); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (c *ContactsRepo) SetStatus(requestID int64, status string, at int64) (bool, error) {
+	res, err := c.sqlDB.Exec(updateRequestStatus, requestID, status, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (c *ContactsRepo) AreContacts(user1ID, user2ID int64) (bool, error) {
+	var ok bool
+	err := c.sqlDB.QueryRow(selectAreContacts, user1ID, user2ID).Scan(&ok)
+	return ok, err
+}
+
+func (c *ContactsRepo) ListContacts(userID int64) ([]*dto.Contact, error) {
+	rows, err := c.sqlDB.Query(selectContacts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := make([]*dto.Contact, 0)
+	for rows.Next() {
+		var (
+			contact  = &dto.Contact{}
+			lastSeen sql.NullInt64
+		)
+		if err := rows.Scan(&contact.User.ID, &contact.User.Username, &contact.Since, &lastSeen); err != nil {
+			return nil, err
+		}
+		contact.LastSeen = lastSeen.Int64
+		contacts = append(contacts, contact)
+	}
+	return contacts, rows.Err()
+}
+
+func (c *ContactsRepo) RemoveContact(user1ID, user2ID int64) (bool, error) {
+	res, err := c.sqlDB.Exec(deleteContact, user1ID, user2ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRequest reads contactRequestColumns followed by any extra columns.
+func scanRequest(row rowScanner, extra ...interface{}) (*dto.ContactRequest, error) {
+	var (
+		req         = &dto.ContactRequest{}
+		respondedAt sql.NullInt64
+	)
+	dest := []interface{}{&req.ID, &req.SenderID, &req.ReceiverID, &req.Status, &req.CreatedAt, &respondedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.RespondedAt = respondedAt.Int64
+	return req, nil
+}
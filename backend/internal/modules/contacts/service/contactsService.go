@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"lilyChat/internal/infrastructure/components"
+	blocksRepo "lilyChat/internal/modules/blocks/repository"
+	contactsRepo "lilyChat/internal/modules/contacts/repository"
+	dto "lilyChat/internal/modules/dto"
+	notifications "lilyChat/internal/modules/notifications/service"
+	usersRepo "lilyChat/internal/modules/users/repository"
+	"lilyChat/internal/modules/webSocket/hub"
+)
+
+// Actions sent with "contact_request" hub events.
+const (
+	RequestReceived  = "received"
+	RequestAccepted  = "accepted"
+	RequestCancelled = "cancelled"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrSelfRequest      = errors.New("you cannot add yourself as a contact")
+	ErrAlreadyContacts  = errors.New("already contacts")
+	ErrNotRequestParty  = errors.New("this contact request is not addressed to you")
+	ErrNotRequestSender = errors.New("only the sender can cancel a contact request")
+)
+
+type ContactsServicer interface {
+	SendRequest(senderID, receiverID int64) (*dto.ContactRequest, error)
+	AcceptRequest(userID, requestID int64) (*dto.ContactRequest, error)
+	DeclineRequest(userID, requestID int64) error
+	CancelRequest(userID, requestID int64) error
+	ListRequests(userID int64, incoming bool) ([]*dto.ContactRequest, error)
+
+	ListContacts(userID int64) ([]*dto.Contact, error)
+	RemoveContact(userID, contactID int64) error
+}
+
+type ContactsService struct {
+	contactsRepo  contactsRepo.ContactsRepositorier
+	usersRepo     usersRepo.UsersRepositorier
+	blocksRepo    blocksRepo.BlocksRepositorier
+	notifications notifications.NotificationsServicer
+	hub           *hub.Hub
+}
+
+func NewContactsService(contactsRepo contactsRepo.ContactsRepositorier, usersRepo usersRepo.UsersRepositorier, blocksRepo blocksRepo.BlocksRepositorier, notifications notifications.NotificationsServicer, components *components.Components) *ContactsService {
+	return &ContactsService{
+		contactsRepo:  contactsRepo,
+		usersRepo:     usersRepo,
+		blocksRepo:    blocksRepo,
+		notifications: notifications,
+		hub:           components.WSHub,
+	}
+}
+
+// SendRequest invites receiverID to become senderID's contact. If
+// receiverID already invited senderID, that request is accepted instead.
+func (s *ContactsService) SendRequest(senderID, receiverID int64) (*dto.ContactRequest, error) {
+	if senderID == receiverID {
+		return nil, ErrSelfRequest
+	}
+
+	exists, err := s.usersRepo.Exists(context.Background(), receiverID)
+	if err != nil {
+		return nil, err
+	}
+	// A block either way looks like an unknown user, so it is not revealed.
+	blocked, err := s.blocksRepo.IsBlockedEither(senderID, receiverID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || blocked {
+		return nil, ErrUserNotFound
+	}
+
+	areContacts, err := s.contactsRepo.AreContacts(senderID, receiverID)
+	if err != nil {
+		return nil, err
+	}
+	if areContacts {
+		return nil, ErrAlreadyContacts
+	}
+
+	pending, err := s.contactsRepo.GetPendingBetween(senderID, receiverID)
+	switch {
+	case err == nil && pending.SenderID == receiverID:
+		return s.AcceptRequest(senderID, pending.ID)
+	case err == nil:
+		return nil, contactsRepo.ErrRequestExists
+	case !errors.Is(err, contactsRepo.ErrRequestNotFound):
+		return nil, err
+	}
+
+	req := &dto.ContactRequest{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := s.contactsRepo.CreateRequest(req); err != nil {
+		return nil, err
+	}
+
+	s.hub.SendContactRequest([]int64{senderID, receiverID}, req, RequestReceived)
+	s.notifications.Notify([]int64{receiverID}, &dto.Notification{
+		Kind:      dto.NotificationContactRequest,
+		SenderID:  senderID,
+		CreatedAt: req.CreatedAt,
+	})
+	return req, nil
+}
+
+func (s *ContactsService) AcceptRequest(userID, requestID int64) (*dto.ContactRequest, error) {
+	req, err := s.contactsRepo.GetRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req.ReceiverID != userID {
+		return nil, ErrNotRequestParty
+	}
+
+	// A request sent before either user blocked the other cannot be
+	// accepted anymore; it looks like it came from an unknown user.
+	blocked, err := s.blocksRepo.IsBlockedEither(req.SenderID, req.ReceiverID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrUserNotFound
+	}
+
+	now := time.Now().Unix()
+	if err := s.contactsRepo.Accept(requestID, now); err != nil {
+		return nil, err
+	}
+	req.Status = dto.ContactRequestAccepted
+	req.RespondedAt = now
+
+	s.hub.SendContactRequest([]int64{req.SenderID, req.ReceiverID}, req, RequestAccepted)
+	return req, nil
+}
+
+// DeclineRequest turns a request down without telling its sender; to them
+// it simply stays unanswered.
+func (s *ContactsService) DeclineRequest(userID, requestID int64) error {
+	req, err := s.contactsRepo.GetRequest(requestID)
+	if err != nil {
+		return err
+	}
+	if req.ReceiverID != userID {
+		return ErrNotRequestParty
+	}
+
+	ok, err := s.contactsRepo.SetStatus(requestID, dto.ContactRequestDeclined, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return contactsRepo.ErrRequestNotFound
+	}
+	return nil
+}
+
+func (s *ContactsService) CancelRequest(userID, requestID int64) error {
+	req, err := s.contactsRepo.GetRequest(requestID)
+	if err != nil {
+		return err
+	}
+	if req.SenderID != userID {
+		return ErrNotRequestSender
+	}
+
+	now := time.Now().Unix()
+	ok, err := s.contactsRepo.SetStatus(requestID, dto.ContactRequestCancelled, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return contactsRepo.ErrRequestNotFound
+	}
+	req.Status = dto.ContactRequestCancelled
+	req.RespondedAt = now
+
+	s.hub.SendContactRequest([]int64{req.SenderID, req.ReceiverID}, req, RequestCancelled)
+	return nil
+}
+
+// ListRequests returns userID's pending requests, received ones when
+// incoming is true and sent ones otherwise.
+func (s *ContactsService) ListRequests(userID int64, incoming bool) ([]*dto.ContactRequest, error) {
+	return s.contactsRepo.ListRequests(userID, incoming)
+}
+
+// ListContacts returns userID's contacts with their current presence.
+// Contacts on either side of a block are left out.
+func (s *ContactsService) ListContacts(userID int64) ([]*dto.Contact, error) {
+	contacts, err := s.contactsRepo.ListContacts(userID)
+	if err != nil {
+		return nil, err
+	}
+	blockedIDs, err := s.blocksRepo.GetBlockedPeers(userID)
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[int64]bool, len(blockedIDs))
+	for _, id := range blockedIDs {
+		blocked[id] = true
+	}
+
+	visible := make([]*dto.Contact, 0, len(contacts))
+	ids := make([]int64, 0, len(contacts))
+	for _, contact := range contacts {
+		if blocked[contact.User.ID] {
+			continue
+		}
+		visible = append(visible, contact)
+		ids = append(ids, contact.User.ID)
+	}
+
+	online := s.hub.Online(ids)
+	for _, contact := range visible {
+		contact.Online = online[contact.User.ID]
+	}
+	return visible, nil
+}
+
+func (s *ContactsService) RemoveContact(userID, contactID int64) error {
+	_, err := s.contactsRepo.RemoveContact(userID, contactID)
+	return err
+}
@@ -3,7 +3,11 @@ package modules
 import (
 	"net/http"
 	"lilyChat/internal/infrastructure/components"
+	attachments "lilyChat/internal/modules/attachments/controller"
 	auth "lilyChat/internal/modules/auth/controller"
+	blocks "lilyChat/internal/modules/blocks/controller"
+	contacts "lilyChat/internal/modules/contacts/controller"
+	notifications "lilyChat/internal/modules/notifications/controller"
 	users "lilyChat/internal/modules/users/controller"
 	wsController "lilyChat/internal/modules/webSocket/controller"
 )
@@ -12,16 +16,36 @@ type Controller struct {
 	Auth auth.Auther
 	Users users.UsersControllers
 	Chat http.HandlerFunc
+	Messages wsController.MessagesController
+	MessageRequests wsController.MessageRequestsController
+	Rooms wsController.RoomsController
+	Attachments attachments.AttachmentsController
+	Blocks blocks.BlocksController
+	Contacts contacts.ContactsController
+	Notifications notifications.NotificationsController
 }
 
 func NewController(services Services, components *components.Components) *Controller {
 	authController := auth.NewAuthController(services.auth, components)
 	usersController := users.NewUsersController(services.users, components)
-	chatHandler := wsController.WSHandler(services.chat)
+	chatHandler := wsController.WSHandler(services.chat, services.rooms, components)
+	messagesController := wsController.NewChatController(services.chat, components)
+	roomsController := wsController.NewRoomController(services.rooms, components)
+	attachmentsController := attachments.NewAttachmentController(services.attachments, components)
+	blocksController := blocks.NewBlockController(services.blocks, components)
+	contactsController := contacts.NewContactController(services.contacts, components)
+	notificationsController := notifications.NewNotificationController(services.notifications, components)
 
 	return &Controller{
 		Auth: authController,
 		Users: *usersController,
 		Chat: chatHandler,
+		Messages: messagesController,
+		MessageRequests: messagesController,
+		Rooms: roomsController,
+		Attachments: attachmentsController,
+		Blocks: blocksController,
+		Contacts: contactsController,
+		Notifications: notificationsController,
 	}
 }
\ No newline at end of file
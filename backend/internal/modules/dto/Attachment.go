@@ -0,0 +1,13 @@
+package dto
+
+type Attachment struct {
+	ID         int64  `json:"id"`
+	OwnerID    int64  `json:"owner_id"`
+	MessageID  int64  `json:"message_id,omitempty"`
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	MimeType   string `json:"mime_type"`
+	Checksum   string `json:"checksum"`
+	StorageKey string `json:"-"`
+	CreatedAt  int64  `json:"created_at"`
+}
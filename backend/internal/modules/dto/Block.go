@@ -0,0 +1,10 @@
+package dto
+
+type BlockedUser struct {
+	User      PublicUser `json:"user"`
+	BlockedAt int64      `json:"blocked_at"`
+}
+
+type BlockRequest struct {
+	UserID int64 `json:"user_id"`
+}
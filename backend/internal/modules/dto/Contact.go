@@ -0,0 +1,32 @@
+package dto
+
+// Contact request statuses.
+const (
+	ContactRequestPending   = "pending"
+	ContactRequestAccepted  = "accepted"
+	ContactRequestDeclined  = "declined"
+	ContactRequestCancelled = "cancelled"
+)
+
+// ContactRequest is an invitation from SenderID to ReceiverID. In lists, User
+// is the other party from the caller's point of view.
+type ContactRequest struct {
+	ID          int64       `json:"id"`
+	SenderID    int64       `json:"sender_id"`
+	ReceiverID  int64       `json:"receiver_id"`
+	Status      string      `json:"status"`
+	CreatedAt   int64       `json:"created_at"`
+	RespondedAt int64       `json:"responded_at,omitempty"`
+	User        *PublicUser `json:"user,omitempty"`
+}
+
+type Contact struct {
+	User     PublicUser `json:"user"`
+	Since    int64      `json:"since"`
+	Online   bool       `json:"online"`
+	LastSeen int64      `json:"last_seen,omitempty"`
+}
+
+type ContactRequestCreate struct {
+	UserID int64 `json:"user_id"`
+}
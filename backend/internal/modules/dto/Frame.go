@@ -0,0 +1,91 @@
+package dto
+
+import "encoding/json"
+
+// WebSocket protocol versions. A client opts into a version with ?v= on the
+// handshake; without it the connection speaks the legacy protocol.
+//
+// Legacy frames are flat JSON objects with a "type" key next to the fields
+// of the request or event, and only failures are answered, with
+// {"type":"error","code":"...","error":"..."} using the ErrorPayload codes.
+//
+// Version 1 frames are Envelopes in both directions:
+//
+//	{"v": 1, "type": "message", "id": "c-42", "payload": {...}}
+//
+// The payload of a request holds the same fields a legacy frame would. When
+// a request carries an id, the server answers it with an "ack" frame with
+// the same id (and the result, if any, as payload) or an "error" frame with
+// the same id and an ErrorPayload. Server events carry no id.
+const (
+	ProtocolLegacy = 0
+	ProtocolV1     = 1
+)
+
+type Envelope struct {
+	V       int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Frame types the server answers requests with.
+const (
+	FrameAck   = "ack"
+	FrameError = "error"
+)
+
+// Error codes sent in an ErrorPayload.
+const (
+	ErrCodeBadRequest  = "bad_request"
+	ErrCodeUnknownType = "unknown_type"
+	ErrCodeForbidden   = "forbidden"
+	ErrCodeNotFound    = "not_found"
+	ErrCodeConflict    = "conflict"
+	ErrCodeRateLimited = "rate_limited"
+
+	ErrCodeEmptyMessage    = "empty_message"
+	ErrCodeMessageTooLong  = "message_too_long"
+	ErrCodeInvalidEncoding = "invalid_encoding"
+	ErrCodeInvalidReceiver = "invalid_receiver"
+)
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Frame types a client may send over the WebSocket. A legacy frame without a
+// type is treated as a SendMessageRequest.
+const (
+	FrameMessage   = "message"
+	FrameDelivered = "delivered"
+	FrameRead      = "read"
+	FrameTyping    = "typing"
+	FrameEdit      = "edit"
+	FrameDelete    = "delete"
+	FrameReaction  = "reaction"
+)
+
+type Frame struct {
+	Type string `json:"type"`
+}
+
+type DeliveredRequest struct {
+	MessageID int64 `json:"message_id"`
+}
+
+type ReadRequest struct {
+	PeerID    int64 `json:"peer_id"`
+	MessageID int64 `json:"message_id"`
+}
+
+type TypingRequest struct {
+	ReceiverID int64  `json:"receiver_id"`
+	State      string `json:"state"`
+}
+
+type DeleteMessageRequest struct {
+	MessageID int64  `json:"message_id"`
+	Scope     string `json:"scope"`
+}
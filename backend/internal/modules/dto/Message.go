@@ -1,14 +1,106 @@
 package dto
 
 type Message struct {
-	ID         int64  `json:"id"`
-	SenderID   int64  `json:"sender_id"`
-	ReceiverID int64  `json:"receiver_id"`
+	ID          int64  `json:"id"`
+	SenderID    int64  `json:"sender_id"`
+	ReceiverID  int64  `json:"receiver_id,omitempty"`
+	RoomID      int64  `json:"room_id,omitempty"`
+	Text        string `json:"text"`
+	CreatedAt   int64  `json:"created_at"`
+	DeliveredAt int64  `json:"delivered_at,omitempty"`
+	ReadAt      int64  `json:"read_at,omitempty"`
+	EditedAt    int64  `json:"edited_at,omitempty"`
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+	ReplyToID   int64  `json:"-"`
+	// IsRequest marks a message held in the receiver's message requests.
+	IsRequest bool `json:"-"`
+
+	ReplyTo       *MessagePreview `json:"reply_to,omitempty"`
+	Reactions     []*Reaction     `json:"reactions,omitempty"`
+	AttachmentIDs []int64         `json:"-"`
+	Attachments   []*Attachment   `json:"attachments,omitempty"`
+}
+
+// MessagePreview is the compact form of a quoted message; Text is cut to
+// the first 100 characters.
+type MessagePreview struct {
+	ID       int64  `json:"id"`
+	SenderID int64  `json:"sender_id"`
+	Text     string `json:"text"`
+	Deleted  bool   `json:"deleted,omitempty"`
+}
+
+// Reaction is the number of users who reacted to a message with Emoji;
+// Reacted tells whether the requesting user is one of them.
+type Reaction struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+type ReactionRequest struct {
+	MessageID int64  `json:"message_id"`
+	Emoji     string `json:"emoji"`
+	Action    string `json:"action"`
+}
+
+// MessageRevision is a message's text as it was before an edit at
+// ReplacedAt.
+type MessageRevision struct {
 	Text       string `json:"text"`
-	CreatedAt  int64  `json:"created_at"`
+	ReplacedAt int64  `json:"replaced_at"`
+}
+
+type EditMessageRequest struct {
+	MessageID int64  `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+type Conversation struct {
+	User          PublicUser `json:"user"`
+	LastMessage   *Message   `json:"last_message"`
+	LastMessageAt int64      `json:"last_message_at"`
+	UnreadCount   int64      `json:"unread_count"`
 }
 
+// SendMessageRequest addresses either a single receiver or a room and may
+// quote an earlier message of the same conversation.
 type SendMessageRequest struct {
 	ReceiverID int64  `json:"receiver_id"`
+	RoomID     int64  `json:"room_id,omitempty"`
 	Text       string `json:"text"`
-}
\ No newline at end of file
+	ReplyTo    int64  `json:"reply_to,omitempty"`
+	// ClientMsgID is an optional ID the client picks for the message, so a
+	// send retried after a network drop is not stored twice.
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+
+	AttachmentIDs []int64 `json:"attachment_ids,omitempty"`
+}
+
+type HistoryQuery struct {
+	BeforeID int64
+	AfterID  int64
+	Limit    int
+}
+
+// SearchQuery filters a full-text search over the caller's messages. Zero
+// values disable a filter; From and To are unix seconds, To exclusive.
+type SearchQuery struct {
+	Text      string
+	PartnerID int64
+	From      int64
+	To        int64
+	BeforeID  int64
+	Limit     int
+}
+
+// SearchResult is a matching message with the matched words wrapped in
+// <mark> tags; the rest of the snippet is HTML-escaped.
+type SearchResult struct {
+	ID         int64  `json:"id"`
+	SenderID   int64  `json:"sender_id"`
+	ReceiverID int64  `json:"receiver_id,omitempty"`
+	RoomID     int64  `json:"room_id,omitempty"`
+	Snippet    string `json:"snippet"`
+	CreatedAt  int64  `json:"created_at"`
+}
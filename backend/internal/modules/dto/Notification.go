@@ -0,0 +1,50 @@
+package dto
+
+// Notification kinds.
+const (
+	NotificationMessage        = "message"
+	NotificationMessageRequest = "message_request"
+	NotificationContactRequest = "contact_request"
+)
+
+// Notification is what a user is alerted about, on top of the regular
+// event that delivers the message or request itself. RoomID is set for
+// room messages; otherwise the conversation is the one with SenderID.
+type Notification struct {
+	Kind      string `json:"kind"`
+	SenderID  int64  `json:"sender_id"`
+	RoomID    int64  `json:"room_id,omitempty"`
+	MessageID int64  `json:"message_id,omitempty"`
+	Text      string `json:"text,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Mute silences notifications from the direct conversation with PeerID or
+// from RoomID. MutedUntil is a unix time, 0 meaning until unmuted.
+type Mute struct {
+	PeerID     int64 `json:"peer_id,omitempty"`
+	RoomID     int64 `json:"room_id,omitempty"`
+	MutedUntil int64 `json:"muted_until,omitempty"`
+	CreatedAt  int64 `json:"created_at"`
+}
+
+// MuteRequest names exactly one of PeerID and RoomID. Either Until (unix
+// time) or Duration (seconds) bounds the mute; with neither it lasts until
+// unmuted.
+type MuteRequest struct {
+	PeerID   int64 `json:"peer_id,omitempty"`
+	RoomID   int64 `json:"room_id,omitempty"`
+	Until    int64 `json:"until,omitempty"`
+	Duration int64 `json:"duration,omitempty"`
+}
+
+// DoNotDisturb is a daily quiet window. Start and End are "HH:MM" in
+// Timezone, an IANA zone name such as "Europe/Berlin"; a window whose End
+// is before its Start runs past midnight, and Start equal to End covers the
+// whole day.
+type DoNotDisturb struct {
+	Enabled  bool   `json:"enabled"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+// Who may start a direct conversation with a user.
+const (
+	PrivacyEveryone = "everyone"
+	PrivacyContacts = "contacts"
+	PrivacyNobody   = "nobody"
+)
+
+// Message request statuses.
+const (
+	MessageRequestPending  = "pending"
+	MessageRequestAccepted = "accepted"
+	MessageRequestRejected = "rejected"
+)
+
+type PrivacySettings struct {
+	MessagePrivacy string `json:"message_privacy"`
+}
+
+// MessageRequest is a conversation a non-contact started with a user whose
+// privacy is set to contacts only. Its messages stay out of the receiver's
+// conversations until the request is accepted.
+type MessageRequest struct {
+	User          PublicUser `json:"user"`
+	CreatedAt     int64      `json:"created_at"`
+	LastText      string     `json:"last_text"`
+	LastMessageAt int64      `json:"last_message_at"`
+	MessageCount  int64      `json:"message_count"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+type Room struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	OwnerID   int64         `json:"owner_id"`
+	CreatedAt int64         `json:"created_at"`
+	Members   []*PublicUser `json:"members,omitempty"`
+}
+
+type CreateRoomRequest struct {
+	Name      string  `json:"name"`
+	MemberIDs []int64 `json:"member_ids"`
+}
+
+type AddRoomMemberRequest struct {
+	UserID int64 `json:"user_id"`
+}
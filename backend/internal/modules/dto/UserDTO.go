@@ -10,4 +10,10 @@ type User struct {
 type PublicUser struct {
     ID       int64  `json:"id"`
     Username string `json:"username"`
-}
\ No newline at end of file
+}
+
+type Presence struct {
+	UserID   int64 `json:"user_id"`
+	Online   bool  `json:"online"`
+	LastSeen int64 `json:"last_seen,omitempty"`
+}
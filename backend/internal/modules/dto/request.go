@@ -0,0 +1,16 @@
+package dto
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// PathID reads the positive ID held by the path parameter name.
+func PathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+	return id, nil
+}
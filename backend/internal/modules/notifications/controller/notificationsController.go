@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	dto "lilyChat/internal/modules/dto"
+	"lilyChat/internal/modules/notifications/service"
+)
+
+type NotificationsController interface {
+	ListMutes(w http.ResponseWriter, r *http.Request)
+	Mute(w http.ResponseWriter, r *http.Request)
+	UnmuteUser(w http.ResponseWriter, r *http.Request)
+	UnmuteRoom(w http.ResponseWriter, r *http.Request)
+	GetDoNotDisturb(w http.ResponseWriter, r *http.Request)
+	UpdateDoNotDisturb(w http.ResponseWriter, r *http.Request)
+}
+
+type NotificationController struct {
+	notificationsService service.NotificationsServicer
+}
+
+func NewNotificationController(service service.NotificationsServicer, components *components.Components) *NotificationController {
+	return &NotificationController{
+		notificationsService: service,
+	}
+}
+
+func (c *NotificationController) ListMutes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	mutes, err := c.notificationsService.ListMutes(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mutes)
+}
+
+// Mute silences a direct conversation (peer_id) or a room (room_id) until
+// the unix time until, for duration seconds, or until unmuted.
+func (c *NotificationController) Mute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req dto.MuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	mute, err := c.notificationsService.Mute(userID, req)
+	if err != nil {
+		http.Error(w, err.Error(), notificationErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mute)
+}
+
+func (c *NotificationController) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	c.unmute(w, r, "userId")
+}
+
+func (c *NotificationController) UnmuteRoom(w http.ResponseWriter, r *http.Request) {
+	c.unmute(w, r, "roomId")
+}
+
+func (c *NotificationController) unmute(w http.ResponseWriter, r *http.Request, param string) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := dto.PathID(r, param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var peerID, roomID int64
+	if param == "roomId" {
+		roomID = id
+	} else {
+		peerID = id
+	}
+
+	if err := c.notificationsService.Unmute(userID, peerID, roomID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "conversation unmuted"})
+}
+
+func (c *NotificationController) GetDoNotDisturb(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	dnd, err := c.notificationsService.GetDoNotDisturb(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dnd)
+}
+
+// UpdateDoNotDisturb saves the caller's daily quiet window, e.g.
+// {"enabled": true, "start": "22:00", "end": "07:00", "timezone": "Europe/Berlin"}.
+func (c *NotificationController) UpdateDoNotDisturb(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req dto.DoNotDisturb
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.notificationsService.UpdateDoNotDisturb(userID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "do not disturb updated"})
+}
+
+func notificationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotRoomMember):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"database/sql"
+	dto "lilyChat/internal/modules/dto"
+
+	"github.com/lib/pq"
+)
+
+const upsertPeerMute = `
+INSERT INTO conversation_mutes (user_id, peer_id, muted_until, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, peer_id) WHERE peer_id IS NOT NULL
+DO UPDATE SET muted_until = EXCLUDED.muted_until, created_at = EXCLUDED.created_at;
+`
+
+const upsertRoomMute = `
+INSERT INTO conversation_mutes (user_id, room_id, muted_until, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, room_id) WHERE room_id IS NOT NULL
+DO UPDATE SET muted_until = EXCLUDED.muted_until, created_at = EXCLUDED.created_at;
+`
+
+const deletePeerMute = `
+DELETE FROM conversation_mutes
+WHERE user_id = $1 AND peer_id = $2;
+`
+
+const deleteRoomMute = `
+DELETE FROM conversation_mutes
+WHERE user_id = $1 AND room_id = $2;
+`
+
+const selectMutes = `
+SELECT peer_id, room_id, muted_until, created_at
+FROM conversation_mutes
+WHERE user_id = $1 AND (muted_until IS NULL OR muted_until > $2)
+ORDER BY created_at DESC;
+`
+
+const selectPeerMuted = `
+SELECT user_id FROM conversation_mutes
+WHERE user_id = ANY($1) AND peer_id = $2
+  AND (muted_until IS NULL OR muted_until > $3);
+`
+
+const selectRoomMuted = `
+SELECT user_id FROM conversation_mutes
+WHERE user_id = ANY($1) AND room_id = $2
+  AND (muted_until IS NULL OR muted_until > $3);
+`
+
+const upsertDoNotDisturb = `
+INSERT INTO do_not_disturb (user_id, enabled, start_time, end_time, timezone, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id)
+DO UPDATE SET enabled = EXCLUDED.enabled, start_time = EXCLUDED.start_time,
+    end_time = EXCLUDED.end_time, timezone = EXCLUDED.timezone, updated_at = EXCLUDED.updated_at;
+`
+
+const selectDoNotDisturb = `
+SELECT user_id, enabled, start_time, end_time, timezone
+FROM do_not_disturb
+WHERE user_id = ANY($1);
+`
+
+type NotificationsRepositorier interface {
+	// Mute creates or replaces userID's mute of the conversation named by
+	// mute.PeerID or mute.RoomID.
+	Mute(userID int64, mute *dto.Mute) error
+	// Unmute reports whether there was a mute to remove.
+	Unmute(userID, peerID, roomID int64) (bool, error)
+	// ListMutes returns userID's mutes still in effect at now.
+	ListMutes(userID, now int64) ([]*dto.Mute, error)
+	// GetMuted returns those of userIDs who muted the conversation with
+	// peerID, or roomID when it is not 0, as of now.
+	GetMuted(userIDs []int64, peerID, roomID, now int64) ([]int64, error)
+
+	SetDoNotDisturb(userID int64, dnd *dto.DoNotDisturb, at int64) error
+	// GetDoNotDisturb returns the saved schedules of userIDs; users who
+	// never set one are left out.
+	GetDoNotDisturb(userIDs []int64) (map[int64]*dto.DoNotDisturb, error)
+}
+
+type NotificationsRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewNotificationsRepo(sqlDB *sql.DB) *NotificationsRepo {
+	return &NotificationsRepo{
+		sqlDB: sqlDB,
+	}
+}
+
+func (n *NotificationsRepo) Mute(userID int64, mute *dto.Mute) error {
+	until := sql.NullInt64{Int64: mute.MutedUntil, Valid: mute.MutedUntil > 0}
+
+	var err error
+	if mute.RoomID != 0 {
+		_, err = n.sqlDB.Exec(upsertRoomMute, userID, mute.RoomID, until, mute.CreatedAt)
+	} else {
+		_, err = n.sqlDB.Exec(upsertPeerMute, userID, mute.PeerID, until, mute.CreatedAt)
+	}
+	return err
+}
+
+func (n *NotificationsRepo) Unmute(userID, peerID, roomID int64) (bool, error) {
+	var (
+		res sql.Result
+		err error
+	)
+	if roomID != 0 {
+		res, err = n.sqlDB.Exec(deleteRoomMute, userID, roomID)
+	} else {
+		res, err = n.sqlDB.Exec(deletePeerMute, userID, peerID)
+	}
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (n *NotificationsRepo) ListMutes(userID, now int64) ([]*dto.Mute, error) {
+	rows, err := n.sqlDB.Query(selectMutes, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := make([]*dto.Mute, 0)
+	for rows.Next() {
+		var (
+			mute   = &dto.Mute{}
+			peerID sql.NullInt64
+			roomID sql.NullInt64
+			until  sql.NullInt64
+		)
+		if err := rows.Scan(&peerID, &roomID, &until, &mute.CreatedAt); err != nil {
+			return nil, err
+		}
+		mute.PeerID = peerID.Int64
+		mute.RoomID = roomID.Int64
+		mute.MutedUntil = until.Int64
+		mutes = append(mutes, mute)
+	}
+	return mutes, rows.Err()
+}
+
+func (n *NotificationsRepo) GetMuted(userIDs []int64, peerID, roomID, now int64) ([]int64, error) {
+	query, conversationID := selectPeerMuted, peerID
+	if roomID != 0 {
+		query, conversationID = selectRoomMuted, roomID
+	}
+
+	rows, err := n.sqlDB.Query(query, pq.Array(userIDs), conversationID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (n *NotificationsRepo) SetDoNotDisturb(userID int64, dnd *dto.DoNotDisturb, at int64) error {
+	_, err := n.sqlDB.Exec(upsertDoNotDisturb, userID, dnd.Enabled, dnd.Start, dnd.End, dnd.Timezone, at)
+	return err
+}
+
+func (n *NotificationsRepo) GetDoNotDisturb(userIDs []int64) (map[int64]*dto.DoNotDisturb, error) {
+	rows, err := n.sqlDB.Query(selectDoNotDisturb, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make(map[int64]*dto.DoNotDisturb, len(userIDs))
+	for rows.Next() {
+		var (
+			userID int64
+			dnd    = &dto.DoNotDisturb{}
+		)
+		if err := rows.Scan(&userID, &dnd.Enabled, &dnd.Start, &dnd.End, &dnd.Timezone); err != nil {
+			return nil, err
+		}
+		schedules[userID] = dnd
+	}
+	return schedules, rows.Err()
+}
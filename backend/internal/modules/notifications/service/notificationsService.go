@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	// The runtime image ships without zoneinfo; embed it so
+	// time.LoadLocation works for do-not-disturb timezones.
+	_ "time/tzdata"
+
+	dto "lilyChat/internal/modules/dto"
+	notificationsRepo "lilyChat/internal/modules/notifications/repository"
+	usersRepo "lilyChat/internal/modules/users/repository"
+	websocket "lilyChat/internal/modules/webSocket"
+)
+
+const clockLayout = "15:04"
+
+const (
+	// notifyWorkers bounds how many notifications are checked and sent at
+	// once, and with it the database queries they make.
+	notifyWorkers = 4
+	// notifyQueueSize is how many notifications may wait for a worker. When
+	// a burst fills the queue, further notifications are dropped; the
+	// messages themselves are still delivered.
+	notifyQueueSize = 1024
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrNotRoomMember = errors.New("not a member of this room")
+)
+
+// defaultDoNotDisturb is reported for users who never saved a schedule.
+var defaultDoNotDisturb = dto.DoNotDisturb{
+	Enabled:  false,
+	Start:    "22:00",
+	End:      "07:00",
+	Timezone: "UTC",
+}
+
+type NotificationsServicer interface {
+	// Notify alerts recipientIDs about n through every Notifier, leaving
+	// out those who muted the conversation or are in their do-not-disturb
+	// window. It returns at once; delivery happens in the background, and
+	// is skipped when too many notifications are already waiting.
+	Notify(recipientIDs []int64, n *dto.Notification)
+
+	Mute(userID int64, req dto.MuteRequest) (*dto.Mute, error)
+	Unmute(userID, peerID, roomID int64) error
+	ListMutes(userID int64) ([]*dto.Mute, error)
+
+	GetDoNotDisturb(userID int64) (*dto.DoNotDisturb, error)
+	UpdateDoNotDisturb(userID int64, dnd dto.DoNotDisturb) error
+}
+
+type NotificationsService struct {
+	notificationsRepo notificationsRepo.NotificationsRepositorier
+	usersRepo         usersRepo.UsersRepositorier
+	roomRepo          websocket.RoomRepository
+	notifiers         []Notifier
+	queue             chan notifyJob
+}
+
+type notifyJob struct {
+	recipientIDs []int64
+	n            *dto.Notification
+}
+
+func NewNotificationsService(notificationsRepo notificationsRepo.NotificationsRepositorier, usersRepo usersRepo.UsersRepositorier, roomRepo websocket.RoomRepository, notifiers ...Notifier) *NotificationsService {
+	s := &NotificationsService{
+		notificationsRepo: notificationsRepo,
+		usersRepo:         usersRepo,
+		roomRepo:          roomRepo,
+		notifiers:         notifiers,
+		queue:             make(chan notifyJob, notifyQueueSize),
+	}
+	for i := 0; i < notifyWorkers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+func (s *NotificationsService) Notify(recipientIDs []int64, n *dto.Notification) {
+	if len(recipientIDs) == 0 || len(s.notifiers) == 0 {
+		return
+	}
+
+	select {
+	case s.queue <- notifyJob{recipientIDs: recipientIDs, n: n}:
+	default:
+		log.Printf("[Notifications] queue full, dropping %s notification for %d users", n.Kind, len(recipientIDs))
+	}
+}
+
+// work sends queued notifications for as long as the service lives.
+func (s *NotificationsService) work() {
+	for job := range s.queue {
+		s.notify(job.recipientIDs, job.n)
+	}
+}
+
+func (s *NotificationsService) notify(recipientIDs []int64, n *dto.Notification) {
+	userIDs, err := s.recipients(recipientIDs, n, time.Now())
+	if err != nil {
+		log.Printf("[Notifications] cannot check settings for %s notification: %v", n.Kind, err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		for _, notifier := range s.notifiers {
+			if err := notifier.Notify(userID, n); err != nil {
+				log.Printf("[Notifications] cannot notify user %d: %v", userID, err)
+			}
+		}
+	}
+}
+
+// recipients filters userIDs down to those who want to hear about n at now.
+func (s *NotificationsService) recipients(userIDs []int64, n *dto.Notification, now time.Time) ([]int64, error) {
+	muted, err := s.notificationsRepo.GetMuted(userIDs, n.SenderID, n.RoomID, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := s.notificationsRepo.GetDoNotDisturb(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[int64]bool, len(muted))
+	for _, id := range muted {
+		skip[id] = true
+	}
+
+	kept := make([]int64, 0, len(userIDs))
+	for _, id := range userIDs {
+		if skip[id] {
+			continue
+		}
+		if dnd, ok := schedules[id]; ok && inQuietHours(dnd, now) {
+			continue
+		}
+		kept = append(kept, id)
+	}
+	return kept, nil
+}
+
+// inQuietHours reports whether now falls into the do-not-disturb window of
+// dnd, read in its own timezone.
+func inQuietHours(dnd *dto.DoNotDisturb, now time.Time) bool {
+	if !dnd.Enabled {
+		return false
+	}
+
+	start, errStart := clockMinutes(dnd.Start)
+	end, errEnd := clockMinutes(dnd.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(dnd.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return minute >= start && minute < end
+	default:
+		return minute >= start || minute < end
+	}
+}
+
+// clockMinutes turns an "HH:MM" time into minutes after midnight.
+func clockMinutes(clock string) (int, error) {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Mute silences notifications from one conversation of userID, either
+// until the requested time or until unmuted. Messages are still delivered.
+// Muting a conversation again replaces the previous mute.
+func (s *NotificationsService) Mute(userID int64, req dto.MuteRequest) (*dto.Mute, error) {
+	if (req.PeerID == 0) == (req.RoomID == 0) {
+		return nil, errors.New("exactly one of peer_id and room_id is required")
+	}
+	if err := s.checkConversation(userID, req.PeerID, req.RoomID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	mute := &dto.Mute{
+		PeerID:    req.PeerID,
+		RoomID:    req.RoomID,
+		CreatedAt: now,
+	}
+	switch {
+	case req.Until != 0 && req.Duration != 0:
+		return nil, errors.New("until and duration cannot both be set")
+	case req.Until != 0:
+		if req.Until <= now {
+			return nil, errors.New("until must be in the future")
+		}
+		mute.MutedUntil = req.Until
+	case req.Duration < 0:
+		return nil, errors.New("duration must be positive")
+	case req.Duration > 0:
+		mute.MutedUntil = now + req.Duration
+	}
+
+	if err := s.notificationsRepo.Mute(userID, mute); err != nil {
+		return nil, err
+	}
+	return mute, nil
+}
+
+func (s *NotificationsService) checkConversation(userID, peerID, roomID int64) error {
+	if roomID != 0 {
+		isMember, err := s.roomRepo.IsMember(roomID, userID)
+		if err != nil {
+			return err
+		}
+		if !isMember {
+			return ErrNotRoomMember
+		}
+		return nil
+	}
+
+	if peerID < 0 || peerID == userID {
+		return errors.New("invalid peer_id")
+	}
+	exists, err := s.usersRepo.Exists(context.Background(), peerID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Unmute lifts a mute; unmuting a conversation that is not muted is a
+// no-op.
+func (s *NotificationsService) Unmute(userID, peerID, roomID int64) error {
+	_, err := s.notificationsRepo.Unmute(userID, peerID, roomID)
+	return err
+}
+
+// ListMutes returns the caller's mutes still in effect, newest first.
+func (s *NotificationsService) ListMutes(userID int64) ([]*dto.Mute, error) {
+	return s.notificationsRepo.ListMutes(userID, time.Now().Unix())
+}
+
+func (s *NotificationsService) GetDoNotDisturb(userID int64) (*dto.DoNotDisturb, error) {
+	schedules, err := s.notificationsRepo.GetDoNotDisturb([]int64{userID})
+	if err != nil {
+		return nil, err
+	}
+	if dnd, ok := schedules[userID]; ok {
+		return dnd, nil
+	}
+
+	dnd := defaultDoNotDisturb
+	return &dnd, nil
+}
+
+// UpdateDoNotDisturb saves userID's quiet window. An empty timezone means
+// UTC.
+func (s *NotificationsService) UpdateDoNotDisturb(userID int64, dnd dto.DoNotDisturb) error {
+	if _, err := clockMinutes(dnd.Start); err != nil {
+		return errors.New("start must be a time such as 22:00")
+	}
+	if _, err := clockMinutes(dnd.End); err != nil {
+		return errors.New("end must be a time such as 07:00")
+	}
+	if dnd.Timezone == "" {
+		dnd.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(dnd.Timezone); err != nil {
+		return errors.New("unknown timezone: " + dnd.Timezone)
+	}
+
+	return s.notificationsRepo.SetDoNotDisturb(userID, &dnd, time.Now().Unix())
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	dto "lilyChat/internal/modules/dto"
+	"lilyChat/internal/modules/webSocket/hub"
+)
+
+// Notifier alerts a user through one channel: in-app, push, email. The
+// NotificationsService decides whether a user is to be alerted at all, so
+// a Notifier only has to deliver.
+type Notifier interface {
+	Notify(userID int64, n *dto.Notification) error
+}
+
+// HubNotifier sends notifications to the user's open connections as
+// "notification" events.
+type HubNotifier struct {
+	hub *hub.Hub
+}
+
+func NewHubNotifier(h *hub.Hub) *HubNotifier {
+	return &HubNotifier{
+		hub: h,
+	}
+}
+
+func (n *HubNotifier) Notify(userID int64, notification *dto.Notification) error {
+	n.hub.SendNotification(userID, notification)
+	return nil
+}
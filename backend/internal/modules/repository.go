@@ -2,6 +2,10 @@ package modules
 
 import (
 	"database/sql"
+	attachments "lilyChat/internal/modules/attachments/repository"
+	blocks "lilyChat/internal/modules/blocks/repository"
+	contacts "lilyChat/internal/modules/contacts/repository"
+	notifications "lilyChat/internal/modules/notifications/repository"
 	"lilyChat/internal/infrastructure/components"
 	auth "lilyChat/internal/modules/auth/repository"
 	users "lilyChat/internal/modules/users/repository"
@@ -13,17 +17,35 @@ type Repository struct {
 	auth 	auth.AuthRepositoryer
 	users 	users.UsersRepositorier
 	chat 	websocket.MessageRepository
+	messageRequests websocket.MessageRequestRepository
+	rooms 	websocket.RoomRepository
+	attachments attachments.AttachmentRepositorier
+	blocks blocks.BlocksRepositorier
+	contacts contacts.ContactsRepositorier
+	notifications notifications.NotificationsRepositorier
 }
 
 func NewRepository(db *sql.DB, componenst *components.Components) *Repository {
 	storageRepo := storage.NewPostgresRepo(db)
 	authRepo 	:= auth.NewAuthRepo(db, storageRepo)
-	users 		:= users.NewUsersRepo(storageRepo)
-	chatRepo 	:= websocket.NewInMemoryMessageRepo()
+	users 		:= users.NewUsersRepo(db, storageRepo)
+	chatRepo 	:= websocket.NewPostgresMessageRepo(db)
+	roomRepo 	:= websocket.NewPostgresRoomRepo(db)
+	messageRequestRepo := websocket.NewPostgresMessageRequestRepo(db)
+	attachmentRepo := attachments.NewAttachmentRepo(db)
+	blocksRepo := blocks.NewBlocksRepo(db)
+	contactsRepo := contacts.NewContactsRepo(db)
+	notificationsRepo := notifications.NewNotificationsRepo(db)
 
 	return &Repository{
 		auth: authRepo,
 		users: users,
 		chat: chatRepo,
+		messageRequests: messageRequestRepo,
+		rooms: roomRepo,
+		attachments: attachmentRepo,
+		blocks: blocksRepo,
+		contacts: contactsRepo,
+		notifications: notificationsRepo,
 	}
 }
\ No newline at end of file
@@ -2,6 +2,10 @@ package modules
 
 import (
 	"lilyChat/internal/infrastructure/components"
+	attachments "lilyChat/internal/modules/attachments/service"
+	blocks "lilyChat/internal/modules/blocks/service"
+	contacts "lilyChat/internal/modules/contacts/service"
+	notifications "lilyChat/internal/modules/notifications/service"
 	auth "lilyChat/internal/modules/auth/service"
 	users "lilyChat/internal/modules/users/service"
 	chatService "lilyChat/internal/modules/webSocket/service"
@@ -11,16 +15,33 @@ type Services struct {
 	auth 	auth.AuthServicer
 	users 	users.UsersServicer
 	chat 	chatService.ChatServicer
+	rooms 	chatService.RoomServicer
+	attachments attachments.AttachmentServicer
+	blocks blocks.BlocksServicer
+	contacts contacts.ContactsServicer
+	notifications notifications.NotificationsServicer
 }
 
 func NewServices(storage Repository, compponents *components.Components) *Services {
 	authService := auth.NewAuthService(storage.auth, compponents.JWT)
-	chatSvc := chatService.NewChatService(storage.chat, compponents.WSHub)
-	usersSvc := users.NewUsersService(storage.users, *compponents) 
+	// Push and email notifiers go next to the hub one once they exist.
+	notificationsSvc := notifications.NewNotificationsService(storage.notifications, storage.users, storage.rooms,
+		notifications.NewHubNotifier(compponents.WSHub))
+	chatSvc := chatService.NewChatService(storage.chat, storage.rooms, storage.messageRequests, storage.users, storage.blocks, storage.contacts, notificationsSvc, compponents)
+	roomSvc := chatService.NewRoomService(storage.rooms, storage.chat, storage.users, storage.blocks, storage.contacts, notificationsSvc, compponents)
+	attachmentSvc := attachments.NewAttachmentService(storage.attachments, compponents)
+	blocksSvc := blocks.NewBlocksService(storage.blocks, storage.users)
+	contactsSvc := contacts.NewContactsService(storage.contacts, storage.users, storage.blocks, notificationsSvc, compponents)
+	usersSvc := users.NewUsersService(storage.users, storage.blocks, *compponents) 
 	
 	return &Services{
 		auth: authService,
 		users: usersSvc,
 		chat: chatSvc,
+		rooms: roomSvc,
+		attachments: attachmentSvc,
+		blocks: blocksSvc,
+		contacts: contactsSvc,
+		notifications: notificationsSvc,
 	}
 }
\ No newline at end of file
@@ -3,8 +3,12 @@ package controller
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	dto "lilyChat/internal/modules/dto"
 	"lilyChat/internal/modules/users/service"
 )
 
@@ -12,6 +16,9 @@ import (
 type UsersController interface {
 	GetAllUsers(w http.ResponseWriter, r *http.Request)
 	GetUserByUsername(w http.ResponseWriter, r *http.Request)
+	GetPresence(w http.ResponseWriter, r *http.Request)
+	GetPrivacy(w http.ResponseWriter, r *http.Request)
+	UpdatePrivacy(w http.ResponseWriter, r *http.Request)
 }
 
 type UsersControllers struct {
@@ -36,6 +43,12 @@ func (c *UsersControllers) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *UsersControllers) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
 	username := r.PathValue("username")
 
 	if username == "" {
@@ -43,7 +56,7 @@ func (c *UsersControllers) GetUserByUsername(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	user, err := c.usersService.GetUserByUsername(r.Context(), username)
+	user, err := c.usersService.GetUserByUsername(r.Context(), userID, username)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -51,4 +64,80 @@ func (c *UsersControllers) GetUserByUsername(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
-}
\ No newline at end of file
+}
+
+// GetPresence answers ?ids=1,2,3 with the online state and last_seen of
+// each listed user the caller has a conversation with or is a contact of.
+func (c *UsersControllers) GetPresence(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	raw := r.URL.Query().Get("ids")
+	if raw == "" {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+
+	var ids []int64
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid user id: "+part, http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	presence, err := c.usersService.GetPresence(r.Context(), userID, ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presence)
+}
+
+func (c *UsersControllers) GetPrivacy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := c.usersService.GetPrivacy(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdatePrivacy sets who may message the caller: "everyone", "contacts"
+// (others land in message requests) or "nobody".
+func (c *UsersControllers) UpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req dto.PrivacySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.usersService.UpdatePrivacy(r.Context(), userID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "privacy updated"})
+}
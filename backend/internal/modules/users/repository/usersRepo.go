@@ -2,23 +2,58 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"lilyChat/internal/infrastructure/db"
 	dto "lilyChat/internal/modules/dto"
+
+	"github.com/lib/pq"
 )
 
+const selectUserExists = `
+SELECT EXISTS (SELECT 1 FROM users WHERE id = $1);
+`
+
+const selectPrivacy = `
+SELECT message_privacy FROM users
+WHERE id = $1;
+`
+
+// selectLastSeen only returns the viewer $1, their contacts and the people
+// they have a conversation with; pending message requests do not count.
+const selectLastSeen = `
+SELECT u.id, u.last_seen FROM users u
+WHERE u.id = ANY($2)
+  AND (
+      u.id = $1
+      OR EXISTS (SELECT 1 FROM contacts c WHERE c.user_id = $1 AND c.contact_id = u.id)
+      OR EXISTS (
+          SELECT 1 FROM messages m
+          WHERE m.room_id IS NULL AND NOT m.is_request
+            AND ((m.sender_id = $1 AND m.receiver_id = u.id) OR (m.sender_id = u.id AND m.receiver_id = $1))
+      )
+  );
+`
+
 type UsersRepositorier interface {
 	FindByUsername(ctx context.Context, username string) (*dto.PublicUser, error)
+	Exists(ctx context.Context, userID int64) (bool, error)
     GetAll(ctx context.Context) ([]*dto.PublicUser, error)
+	UpdateLastSeen(ctx context.Context, userID int64, lastSeen int64) error
+	GetLastSeen(ctx context.Context, viewerID int64, userIDs []int64) (map[int64]int64, error)
+	GetPrivacy(ctx context.Context, userID int64) (string, error)
+	SetPrivacy(ctx context.Context, userID int64, privacy string) error
 }
 
 type UsersRepo struct {
+	sqlDB *sql.DB
 	repo  db.Repository
 	table string
 }
 
-func NewUsersRepo(repo db.Repository) *UsersRepo {
+func NewUsersRepo(sqlDB *sql.DB, repo db.Repository) *UsersRepo {
 	return &UsersRepo{
+		sqlDB: sqlDB,
 		repo:  repo,
 		table: "users",
 	}
@@ -67,4 +102,73 @@ func (u *UsersRepo) FindByUsername(ctx context.Context, username string) (*dto.P
 	}
 
 	return user, nil
-}
\ No newline at end of file
+}
+
+func (u *UsersRepo) Exists(ctx context.Context, userID int64) (bool, error) {
+	var exists bool
+	err := u.sqlDB.QueryRowContext(ctx, selectUserExists, userID).Scan(&exists)
+	return exists, err
+}
+
+func (u *UsersRepo) UpdateLastSeen(ctx context.Context, userID int64, lastSeen int64) error {
+	filters := db.Record{
+		"id": userID,
+	}
+	updates := db.Record{
+		"last_seen": lastSeen,
+	}
+
+	return u.repo.Update(u.table, filters, updates)
+}
+
+// GetLastSeen returns the last_seen timestamp of every user in userIDs that
+// viewerID may see: themselves, their contacts and their conversation
+// partners. Users that never connected map to 0.
+func (u *UsersRepo) GetLastSeen(ctx context.Context, viewerID int64, userIDs []int64) (map[int64]int64, error) {
+	rows, err := u.sqlDB.QueryContext(ctx, selectLastSeen, viewerID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lastSeen := make(map[int64]int64, len(userIDs))
+	for rows.Next() {
+		var (
+			id   int64
+			seen sql.NullInt64
+		)
+		if err := rows.Scan(&id, &seen); err != nil {
+			return nil, err
+		}
+		lastSeen[id] = seen.Int64
+	}
+
+	return lastSeen, rows.Err()
+}
+
+func (u *UsersRepo) GetPrivacy(ctx context.Context, userID int64) (string, error) {
+	var privacy sql.NullString
+	err := u.sqlDB.QueryRowContext(ctx, selectPrivacy, userID).Scan(&privacy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("user not found")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if privacy.String == "" {
+		return dto.PrivacyEveryone, nil
+	}
+	return privacy.String, nil
+}
+
+func (u *UsersRepo) SetPrivacy(ctx context.Context, userID int64, privacy string) error {
+	filters := db.Record{
+		"id": userID,
+	}
+	updates := db.Record{
+		"message_privacy": privacy,
+	}
+
+	return u.repo.Update(u.table, filters, updates)
+}
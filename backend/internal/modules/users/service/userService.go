@@ -2,23 +2,35 @@ package service
 
 import (
 	"context"
+	"errors"
 	"lilyChat/internal/infrastructure/components"
+	blocksRepo "lilyChat/internal/modules/blocks/repository"
 	dto "lilyChat/internal/modules/dto"
 	usersRepo "lilyChat/internal/modules/users/repository"
+	"lilyChat/internal/modules/webSocket/hub"
 )
 
+const maxPresenceIDs = 100
+
 type UsersServicer interface {
 	GetAllUsers(ctx context.Context) ([]*dto.PublicUser, error)
-	GetUserByUsername(ctx context.Context, username string) (*dto.PublicUser, error)
+	GetUserByUsername(ctx context.Context, viewerID int64, username string) (*dto.PublicUser, error)
+	GetPresence(ctx context.Context, viewerID int64, userIDs []int64) ([]*dto.Presence, error)
+	GetPrivacy(ctx context.Context, userID int64) (*dto.PrivacySettings, error)
+	UpdatePrivacy(ctx context.Context, userID int64, settings dto.PrivacySettings) error
 }
 
 type UsersService struct {
-	usersRepo usersRepo.UsersRepositorier
+	usersRepo  usersRepo.UsersRepositorier
+	blocksRepo blocksRepo.BlocksRepositorier
+	hub        *hub.Hub
 }
 
-func NewUsersService(repo usersRepo.UsersRepositorier, components components.Components) *UsersService {
+func NewUsersService(repo usersRepo.UsersRepositorier, blocksRepo blocksRepo.BlocksRepositorier, components components.Components) *UsersService {
 	return &UsersService{
-		usersRepo: repo,
+		usersRepo:  repo,
+		blocksRepo: blocksRepo,
+		hub:        components.WSHub,
 	}
 }
 
@@ -26,6 +38,79 @@ func (s *UsersService) GetAllUsers(ctx context.Context) ([]*dto.PublicUser, erro
 	return s.usersRepo.GetAll(ctx)
 }
 
-func (s *UsersService) GetUserByUsername(ctx context.Context, username string) (*dto.PublicUser, error) {
-	return s.usersRepo.FindByUsername(ctx, username)
+// GetUserByUsername looks a user up for viewerID. Users who blocked the
+// viewer are reported as not found.
+func (s *UsersService) GetUserByUsername(ctx context.Context, viewerID int64, username string) (*dto.PublicUser, error) {
+	user, err := s.usersRepo.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := s.blocksRepo.IsBlocked(user.ID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// GetPresence reports to viewerID whether each user currently has a live
+// connection and when they were last seen. Only the viewer's contacts and
+// conversation partners are reported; anyone else, including users
+// blocking or blocked by the viewer, is left out of the result.
+func (s *UsersService) GetPresence(ctx context.Context, viewerID int64, userIDs []int64) ([]*dto.Presence, error) {
+	if len(userIDs) > maxPresenceIDs {
+		return nil, errors.New("too many user ids")
+	}
+
+	lastSeen, err := s.usersRepo.GetLastSeen(ctx, viewerID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	blockedIDs, err := s.blocksRepo.GetBlockedPeers(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range blockedIDs {
+		delete(lastSeen, id)
+	}
+
+	online := s.hub.Online(userIDs)
+	presence := make([]*dto.Presence, 0, len(lastSeen))
+	for _, id := range userIDs {
+		seen, ok := lastSeen[id]
+		if !ok {
+			continue
+		}
+		presence = append(presence, &dto.Presence{
+			UserID:   id,
+			Online:   online[id],
+			LastSeen: seen,
+		})
+	}
+
+	return presence, nil
+}
+
+func (s *UsersService) GetPrivacy(ctx context.Context, userID int64) (*dto.PrivacySettings, error) {
+	privacy, err := s.usersRepo.GetPrivacy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.PrivacySettings{MessagePrivacy: privacy}, nil
+}
+
+// UpdatePrivacy changes who may message userID. Conversations that already
+// exist are not affected.
+func (s *UsersService) UpdatePrivacy(ctx context.Context, userID int64, settings dto.PrivacySettings) error {
+	switch settings.MessagePrivacy {
+	case dto.PrivacyEveryone, dto.PrivacyContacts, dto.PrivacyNobody:
+	default:
+		return errors.New("message_privacy must be everyone, contacts or nobody")
+	}
+
+	return s.usersRepo.SetPrivacy(ctx, userID, settings.MessagePrivacy)
 }
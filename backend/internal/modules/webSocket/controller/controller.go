@@ -1,10 +1,18 @@
 package controller
 
 import (
+	"encoding/json"
+	"expvar"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	dto "lilyChat/internal/modules/dto"
+	"lilyChat/internal/infrastructure/components"
 	"lilyChat/internal/infrastructure/middleware"
+	"lilyChat/internal/modules/webSocket/hub"
+	"lilyChat/internal/modules/webSocket/ratelimit"
 	"lilyChat/internal/modules/webSocket/service"
 
 	"github.com/gorilla/websocket"
@@ -16,7 +24,37 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func WSHandler(chatSvc service.ChatServicer) http.HandlerFunc {
+// rateLimiter is the limiter of the most recently built WSHandler, whose
+// counters the "ws_rate_limit" expvar reports. The expvar is published
+// once, as expvar.Publish panics on a name that is already taken.
+var rateLimiter atomic.Pointer[ratelimit.Limiter]
+
+func init() {
+	expvar.Publish("ws_rate_limit", expvar.Func(func() any {
+		if limiter := rateLimiter.Load(); limiter != nil {
+			return limiter.Stats()
+		}
+		return nil
+	}))
+}
+
+// WSHandler upgrades the request to a WebSocket. ?v= picks the protocol
+// version (see dto.Envelope), legacy when absent. A client reconnecting
+// after a drop passes the newest message ID it has as ?last_message_id= and
+// gets everything it missed before live messages.
+//
+// Incoming frames are rate limited per user; the limiter's counters are
+// published as the "ws_rate_limit" expvar. A user the limiter disconnects
+// loses all their connections and is refused for the configured penalty.
+func WSHandler(chatSvc service.ChatServicer, roomSvc service.RoomServicer, components *components.Components) http.HandlerFunc {
+	handlers := frameHandlers(chatSvc, roomSvc)
+	wsHub := components.WSHub
+	maxFrameSize := components.Conf.Chat.MaxFrameSize
+
+	limits := components.Conf.RateLimit
+	limiter := ratelimit.NewLimiter(limits.Rate, limits.Burst, limits.MaxViolations, limits.ViolationWindow)
+	rateLimiter.Store(limiter)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := middleware.GetUserIDFromContext(r.Context())
 		if !ok {
@@ -24,34 +62,162 @@ func WSHandler(chatSvc service.ChatServicer) http.HandlerFunc {
 			return
 		}
 
+		if until, suspended := wsHub.IsSuspended(userID); suspended {
+			retryAfter := int(time.Until(until).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "too many frames, try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		version := dto.ProtocolLegacy
+		if raw := r.URL.Query().Get("v"); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v != dto.ProtocolV1 {
+				http.Error(w, "unsupported protocol version", http.StatusBadRequest)
+				return
+			}
+			version = v
+		}
+
+		var lastSeenID int64
+		if raw := r.URL.Query().Get("last_message_id"); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id < 0 {
+				http.Error(w, "invalid last_message_id", http.StatusBadRequest)
+				return
+			}
+			lastSeenID = id
+		}
+
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			http.Error(w, "Failed to upgrade connection", http.StatusInternalServerError)
 			return
 		}
-		defer conn.Close()
 
-		chatSvc.GetHub().Register(userID, conn)
-		defer chatSvc.GetHub().Unregister(userID)
+		// Frames over the limit make gorilla close the connection with
+		// 1009 (message too big) instead of buffering them.
+		conn.SetReadLimit(maxFrameSize)
 
-		conn.WriteJSON(map[string]interface{}{
+		client := hub.NewClient(userID, conn, version)
+		go client.WritePump()
+
+		client.Send(map[string]interface{}{
 			"type":    "connected",
 			"user_id": userID,
 			"message": "WebSocket connection established",
 		})
 
-		for {
-			var req dto.SendMessageRequest
-			if err := conn.ReadJSON(&req); err != nil {
-				break
+		chatSvc.Connect(client, lastSeenID)
+		defer chatSvc.Disconnect(client)
+
+		client.ReadPump(func(data []byte) {
+			switch limiter.Allow(userID) {
+			case ratelimit.Rejected:
+				sendError(client, errRateLimited)
+				return
+			case ratelimit.Disconnect:
+				sendError(client, errRateLimited)
+				wsHub.Suspend(userID, time.Now().Add(limits.Penalty))
+				return
 			}
-			
-			if err := chatSvc.SendMessage(userID, req.ReceiverID, req.Text); err != nil {
-				conn.WriteJSON(map[string]interface{}{
-					"type":  "error",
-					"error": err.Error(),
-				})
+
+			if version == dto.ProtocolLegacy {
+				serveLegacyFrame(client, handlers, data)
+			} else {
+				serveEnvelope(client, handlers, data)
 			}
-		}
+		})
+	}
+}
+
+// serveLegacyFrame handles a flat frame whose fields double as the payload.
+func serveLegacyFrame(client *hub.Client, handlers map[string]frameHandler, data []byte) {
+	if reply := answerLegacyFrame(client.UserID, handlers, data); reply != nil {
+		client.Send(reply)
+	}
+}
+
+// answerLegacyFrame runs a legacy frame and returns the error frame to send
+// back, or nil when it succeeded.
+func answerLegacyFrame(userID int64, handlers map[string]frameHandler, data []byte) map[string]interface{} {
+	var frame dto.Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return legacyError(errInvalidFrame)
 	}
+	if frame.Type == "" {
+		frame.Type = dto.FrameMessage
+	}
+
+	handler, ok := handlers[frame.Type]
+	if !ok {
+		return legacyError(errUnknownType(frame.Type))
+	}
+	if _, err := handler(userID, data); err != nil {
+		return legacyError(err)
+	}
+	return nil
+}
+
+// serveEnvelope handles a versioned frame and, if it carries an id, answers
+// with an ack or error frame carrying the same id.
+func serveEnvelope(client *hub.Client, handlers map[string]frameHandler, data []byte) {
+	if reply, ok := answerEnvelope(client.UserID, handlers, data); ok {
+		client.SendFrame(reply)
+	}
+}
+
+// answerEnvelope runs a versioned frame and returns the frame to send back,
+// if any: errors are always reported, successes only to frames with an id.
+func answerEnvelope(userID int64, handlers map[string]frameHandler, data []byte) (dto.Envelope, bool) {
+	var env dto.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return envelopeError("", errInvalidFrame), true
+	}
+	if env.V != dto.ProtocolV1 {
+		return envelopeError(env.ID, errUnsupportedVersion), true
+	}
+
+	handler, ok := handlers[env.Type]
+	if !ok {
+		return envelopeError(env.ID, errUnknownType(env.Type)), true
+	}
+
+	payload := env.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+
+	result, err := handler(userID, payload)
+	if err != nil {
+		return envelopeError(env.ID, err), true
+	}
+	if env.ID == "" {
+		return dto.Envelope{}, false
+	}
+	return hub.NewEnvelope(dto.FrameAck, env.ID, result), true
+}
+
+// sendError reports an error that is not tied to a particular request.
+func sendError(client *hub.Client, err error) {
+	if client.Version == dto.ProtocolLegacy {
+		client.Send(legacyError(err))
+	} else {
+		client.SendFrame(envelopeError("", err))
+	}
+}
+
+func legacyError(err error) map[string]interface{} {
+	return map[string]interface{}{
+		"type":  dto.FrameError,
+		"code":  frameErrorCode(err),
+		"error": err.Error(),
+	}
+}
+
+func envelopeError(id string, err error) dto.Envelope {
+	return hub.NewEnvelope(dto.FrameError, id, dto.ErrorPayload{
+		Code:    frameErrorCode(err),
+		Message: err.Error(),
+	})
 }
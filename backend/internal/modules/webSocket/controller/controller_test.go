@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"lilyChat/internal/infrastructure/components"
+	dto "lilyChat/internal/modules/dto"
+	"lilyChat/internal/modules/webSocket/hub"
+	"lilyChat/internal/modules/webSocket/service"
+)
+
+func TestWSHandlerCanBeBuiltTwice(t *testing.T) {
+	comps := &components.Components{WSHub: hub.NewHub()}
+
+	// Publishing the expvar from WSHandler itself would panic here.
+	WSHandler(nil, nil, comps)
+	WSHandler(nil, nil, comps)
+}
+
+// testHandlers answer "echo" with its payload and fail message frames with an
+// empty message error.
+func testHandlers() map[string]frameHandler {
+	return map[string]frameHandler{
+		"echo": func(userID int64, payload json.RawMessage) (interface{}, error) {
+			return payload, nil
+		},
+		dto.FrameMessage: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			return nil, service.ErrEmptyMessage
+		},
+	}
+}
+
+func TestAnswerEnvelope(t *testing.T) {
+	tests := []struct {
+		name        string
+		frame       string
+		wantReply   bool
+		wantType    string
+		wantID      string
+		wantCode    string
+		wantPayload string
+	}{
+		{"ack echoes the id and result", `{"v":1,"type":"echo","id":"c-1","payload":{"a":1}}`,
+			true, dto.FrameAck, "c-1", "", `{"a":1}`},
+		{"missing payload reads as empty", `{"v":1,"type":"echo","id":"c-2"}`,
+			true, dto.FrameAck, "c-2", "", `{}`},
+		{"success without id is not acked", `{"v":1,"type":"echo","payload":{}}`,
+			false, "", "", "", ""},
+		{"handler error keeps the id", `{"v":1,"type":"message","id":"c-3","payload":{}}`,
+			true, dto.FrameError, "c-3", dto.ErrCodeEmptyMessage, ""},
+		{"handler error without id is still reported", `{"v":1,"type":"message","payload":{}}`,
+			true, dto.FrameError, "", dto.ErrCodeEmptyMessage, ""},
+		{"unknown type", `{"v":1,"type":"nope","id":"c-4"}`,
+			true, dto.FrameError, "c-4", dto.ErrCodeUnknownType, ""},
+		{"unsupported version", `{"v":2,"type":"echo","id":"c-5"}`,
+			true, dto.FrameError, "c-5", dto.ErrCodeBadRequest, ""},
+		{"invalid json", `{"v":1,`,
+			true, dto.FrameError, "", dto.ErrCodeBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, ok := answerEnvelope(1, testHandlers(), []byte(tt.frame))
+			if ok != tt.wantReply {
+				t.Fatalf("reply sent = %v, want %v", ok, tt.wantReply)
+			}
+			if !ok {
+				return
+			}
+			if reply.V != dto.ProtocolV1 || reply.Type != tt.wantType || reply.ID != tt.wantID {
+				t.Errorf("got v=%d type=%q id=%q, want type=%q id=%q", reply.V, reply.Type, reply.ID, tt.wantType, tt.wantID)
+			}
+
+			if tt.wantType == dto.FrameAck {
+				if string(reply.Payload) != tt.wantPayload {
+					t.Errorf("payload = %s, want %s", reply.Payload, tt.wantPayload)
+				}
+				return
+			}
+			var errPayload dto.ErrorPayload
+			if err := json.Unmarshal(reply.Payload, &errPayload); err != nil {
+				t.Fatal(err)
+			}
+			if errPayload.Code != tt.wantCode || errPayload.Message == "" {
+				t.Errorf("error payload = %+v, want code %q with a message", errPayload, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAnswerLegacyFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		wantCode string
+	}{
+		{"success is not answered", `{"type":"echo"}`, ""},
+		{"frame without type is a message", `{"receiver_id":2,"text":""}`, dto.ErrCodeEmptyMessage},
+		{"unknown type", `{"type":"nope"}`, dto.ErrCodeUnknownType},
+		{"invalid json", `{`, dto.ErrCodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := answerLegacyFrame(1, testHandlers(), []byte(tt.frame))
+			if tt.wantCode == "" {
+				if reply != nil {
+					t.Errorf("got reply %v, want none", reply)
+				}
+				return
+			}
+			if reply == nil {
+				t.Fatal("got no reply, want an error frame")
+			}
+			if reply["type"] != dto.FrameError || reply["code"] != tt.wantCode || reply["error"] == "" {
+				t.Errorf("got %v, want an error frame with code %q", reply, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFrameErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errRateLimited, dto.ErrCodeRateLimited},
+		{errUnknownType("x"), dto.ErrCodeUnknownType},
+		{service.ErrMessageTooLong, dto.ErrCodeMessageTooLong},
+		{service.ErrReceiverNotFound, dto.ErrCodeInvalidReceiver},
+		{service.ErrBlocked, dto.ErrCodeForbidden},
+		{service.ErrClientMsgIDConflict, dto.ErrCodeConflict},
+		{errors.New("anything else"), dto.ErrCodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		if got := frameErrorCode(tt.err); got != tt.want {
+			t.Errorf("frameErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
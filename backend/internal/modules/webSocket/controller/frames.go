@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	dto "lilyChat/internal/modules/dto"
+	websocket "lilyChat/internal/modules/webSocket"
+	"lilyChat/internal/modules/webSocket/service"
+)
+
+// frameHandler serves one WebSocket frame type. payload holds the request
+// fields; the returned value, if not nil, is sent back in the ack.
+type frameHandler func(userID int64, payload json.RawMessage) (interface{}, error)
+
+var (
+	errInvalidFrame       = errors.New("invalid frame")
+	errUnsupportedVersion = errors.New("unsupported protocol version")
+	errRateLimited        = errors.New("rate limit exceeded, slow down")
+)
+
+type unknownTypeError string
+
+func (e unknownTypeError) Error() string {
+	return fmt.Sprintf("unknown frame type %q", string(e))
+}
+
+func errUnknownType(frameType string) error {
+	return unknownTypeError(frameType)
+}
+
+// frameHandlers is the registry of frame types a client may send. Adding a
+// feature means adding an entry here; clients that never send the new type
+// are unaffected.
+func frameHandlers(chatSvc service.ChatServicer, roomSvc service.RoomServicer) map[string]frameHandler {
+	return map[string]frameHandler{
+		dto.FrameMessage: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.SendMessageRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid message frame")
+			}
+			if req.RoomID != 0 {
+				return roomSvc.SendMessage(userID, req)
+			}
+			return chatSvc.SendMessage(userID, req)
+		},
+
+		dto.FrameDelivered: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.DeliveredRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid delivered frame")
+			}
+			return nil, chatSvc.AckDelivered(userID, req.MessageID)
+		},
+
+		dto.FrameRead: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.ReadRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid read frame")
+			}
+			return nil, chatSvc.MarkRead(userID, req.PeerID, req.MessageID)
+		},
+
+		dto.FrameTyping: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.TypingRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid typing frame")
+			}
+			return nil, chatSvc.SetTyping(userID, req.ReceiverID, req.State)
+		},
+
+		dto.FrameEdit: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.EditMessageRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid edit frame")
+			}
+			return chatSvc.EditMessage(userID, req.MessageID, req.Text)
+		},
+
+		dto.FrameDelete: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.DeleteMessageRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid delete frame")
+			}
+			return nil, chatSvc.DeleteMessage(userID, req.MessageID, req.Scope)
+		},
+
+		dto.FrameReaction: func(userID int64, payload json.RawMessage) (interface{}, error) {
+			var req dto.ReactionRequest
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, errors.New("invalid reaction frame")
+			}
+			return nil, chatSvc.React(userID, req.MessageID, req.Emoji, req.Action)
+		},
+	}
+}
+
+func frameErrorCode(err error) string {
+	var unknownType unknownTypeError
+	switch {
+	case errors.As(err, &unknownType):
+		return dto.ErrCodeUnknownType
+	case errors.Is(err, errRateLimited):
+		return dto.ErrCodeRateLimited
+	case errors.Is(err, service.ErrEmptyMessage):
+		return dto.ErrCodeEmptyMessage
+	case errors.Is(err, service.ErrMessageTooLong):
+		return dto.ErrCodeMessageTooLong
+	case errors.Is(err, service.ErrInvalidEncoding):
+		return dto.ErrCodeInvalidEncoding
+	case errors.Is(err, service.ErrReceiverNotFound), errors.Is(err, service.ErrSelfMessage):
+		return dto.ErrCodeInvalidReceiver
+	case errors.Is(err, websocket.ErrMessageNotFound), errors.Is(err, websocket.ErrRoomNotFound):
+		return dto.ErrCodeNotFound
+	case errors.Is(err, service.ErrClientMsgIDConflict):
+		return dto.ErrCodeConflict
+	case errors.Is(err, service.ErrNotMessageSender),
+		errors.Is(err, service.ErrNotParticipant),
+		errors.Is(err, service.ErrNotRoomMember),
+		errors.Is(err, service.ErrNotRoomOwner),
+		errors.Is(err, service.ErrBlocked),
+		errors.Is(err, service.ErrNotAccepting):
+		return dto.ErrCodeForbidden
+	default:
+		return dto.ErrCodeBadRequest
+	}
+}
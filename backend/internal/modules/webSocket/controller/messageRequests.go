@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"lilyChat/internal/infrastructure/middleware"
+	dto "lilyChat/internal/modules/dto"
+	websocket "lilyChat/internal/modules/webSocket"
+)
+
+// MessageRequestsController serves the inbox of messages held back by the
+// caller's "contacts" privacy setting. Requests are addressed by the
+// sender's user ID.
+type MessageRequestsController interface {
+	ListMessageRequests(w http.ResponseWriter, r *http.Request)
+	GetRequestMessages(w http.ResponseWriter, r *http.Request)
+	AcceptMessageRequest(w http.ResponseWriter, r *http.Request)
+	RejectMessageRequest(w http.ResponseWriter, r *http.Request)
+}
+
+func (c *ChatController) ListMessageRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	requests, err := c.chatService.ListMessageRequests(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// GetRequestMessages returns the latest messages of the request from
+// {userId}, oldest first; limit caps how many.
+func (c *ChatController) GetRequestMessages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	senderID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var limit int
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, errInvalidParam("limit").Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	messages, err := c.chatService.GetRequestMessages(userID, senderID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (c *ChatController) AcceptMessageRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	senderID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.chatService.AcceptMessageRequest(userID, senderID); err != nil {
+		http.Error(w, err.Error(), messageRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "message request accepted"})
+}
+
+func (c *ChatController) RejectMessageRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	senderID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.chatService.RejectMessageRequest(userID, senderID); err != nil {
+		http.Error(w, err.Error(), messageRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "message request rejected"})
+}
+
+func messageRequestErrorStatus(err error) int {
+	if errors.Is(err, websocket.ErrMessageRequestNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	dto "lilyChat/internal/modules/dto"
+	websocket "lilyChat/internal/modules/webSocket"
+	"lilyChat/internal/modules/webSocket/service"
+)
+
+type MessagesController interface {
+	GetHistory(w http.ResponseWriter, r *http.Request)
+	GetInbox(w http.ResponseWriter, r *http.Request)
+	Search(w http.ResponseWriter, r *http.Request)
+	EditMessage(w http.ResponseWriter, r *http.Request)
+	GetRevisions(w http.ResponseWriter, r *http.Request)
+	DeleteMessage(w http.ResponseWriter, r *http.Request)
+	AddReaction(w http.ResponseWriter, r *http.Request)
+	RemoveReaction(w http.ResponseWriter, r *http.Request)
+}
+
+type ChatController struct {
+	chatService service.ChatServicer
+}
+
+func NewChatController(chatService service.ChatServicer, components *components.Components) *ChatController {
+	return &ChatController{
+		chatService: chatService,
+	}
+}
+
+// GetHistory returns one page of the conversation with {userId}, oldest
+// message first. The before/after query params are message ID cursors and
+// limit caps the page size.
+func (c *ChatController) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	peerID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := c.chatService.GetConversation(userID, peerID, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// GetInbox lists everyone the caller has exchanged messages with, most
+// recently active conversation first.
+func (c *ChatController) GetInbox(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	inbox, err := c.chatService.GetInbox(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inbox)
+}
+
+func (c *ChatController) EditMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := dto.PathID(r, "messageId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req dto.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := c.chatService.EditMessage(userID, messageID, req.Text)
+	if err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// GetRevisions lists the earlier versions of an edited message, oldest
+// first.
+func (c *ChatController) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := dto.PathID(r, "messageId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := c.chatService.GetRevisions(userID, messageID)
+	if err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// DeleteMessage removes a message; ?scope=me (default) hides it for the
+// caller only, ?scope=everyone retracts it for all participants.
+func (c *ChatController) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := dto.PathID(r, "messageId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if err := c.chatService.DeleteMessage(userID, messageID, scope); err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "message deleted"})
+}
+
+func (c *ChatController) AddReaction(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := dto.PathID(r, "messageId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req dto.ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.chatService.React(userID, messageID, req.Emoji, service.ReactionAdd); err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "reaction added"})
+}
+
+// RemoveReaction takes the emoji as ?emoji= so it does not have to be
+// escaped into the path.
+func (c *ChatController) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := dto.PathID(r, "messageId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	emoji := r.URL.Query().Get("emoji")
+	if err := c.chatService.React(userID, messageID, emoji, service.ReactionRemove); err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "reaction removed"})
+}
+
+// Search runs a full-text search over the caller's messages. q is the search
+// text; with (a user ID), from and to (unix seconds) narrow it down, and
+// before and limit page through the results, newest first.
+func (c *ChatController) Search(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := c.chatService.Search(userID, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, websocket.ErrMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotMessageSender), errors.Is(err, service.ErrNotParticipant):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func parseHistoryQuery(r *http.Request) (dto.HistoryQuery, error) {
+	var query dto.HistoryQuery
+	values := r.URL.Query()
+
+	if raw := values.Get("before"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return query, errInvalidParam("before")
+		}
+		query.BeforeID = id
+	}
+
+	if raw := values.Get("after"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return query, errInvalidParam("after")
+		}
+		query.AfterID = id
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return query, errInvalidParam("limit")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func parseSearchQuery(r *http.Request) (dto.SearchQuery, error) {
+	values := r.URL.Query()
+	query := dto.SearchQuery{Text: values.Get("q")}
+
+	params := []struct {
+		name string
+		dst  *int64
+	}{
+		{"with", &query.PartnerID},
+		{"from", &query.From},
+		{"to", &query.To},
+		{"before", &query.BeforeID},
+	}
+	for _, p := range params {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 {
+			return query, errInvalidParam(p.name)
+		}
+		*p.dst = v
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return query, errInvalidParam("limit")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func errInvalidParam(name string) error {
+	return fmt.Errorf("invalid %s parameter", name)
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/middleware"
+	dto "lilyChat/internal/modules/dto"
+	websocket "lilyChat/internal/modules/webSocket"
+	"lilyChat/internal/modules/webSocket/service"
+)
+
+type RoomsController interface {
+	CreateRoom(w http.ResponseWriter, r *http.Request)
+	ListRooms(w http.ResponseWriter, r *http.Request)
+	GetRoom(w http.ResponseWriter, r *http.Request)
+	AddMember(w http.ResponseWriter, r *http.Request)
+	RemoveMember(w http.ResponseWriter, r *http.Request)
+	GetHistory(w http.ResponseWriter, r *http.Request)
+}
+
+type RoomController struct {
+	roomService service.RoomServicer
+}
+
+func NewRoomController(roomService service.RoomServicer, components *components.Components) *RoomController {
+	return &RoomController{
+		roomService: roomService,
+	}
+}
+
+func (c *RoomController) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req dto.CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	room, err := c.roomService.CreateRoom(userID, req.Name, req.MemberIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(room)
+}
+
+func (c *RoomController) ListRooms(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	rooms, err := c.roomService.ListRooms(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
+}
+
+func (c *RoomController) GetRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := dto.PathID(r, "roomId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	room, err := c.roomService.GetRoom(userID, roomID)
+	if err != nil {
+		http.Error(w, err.Error(), roomErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+func (c *RoomController) AddMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := dto.PathID(r, "roomId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req dto.AddRoomMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.roomService.AddMember(userID, roomID, req.UserID); err != nil {
+		http.Error(w, err.Error(), roomErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "member added"})
+}
+
+func (c *RoomController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := dto.PathID(r, "roomId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := dto.PathID(r, "userId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.roomService.RemoveMember(userID, roomID, memberID); err != nil {
+		http.Error(w, err.Error(), roomErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.Response{Message: "member removed"})
+}
+
+// GetHistory pages through a room's messages with the same before/after/limit
+// parameters as the direct message history.
+func (c *RoomController) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := dto.PathID(r, "roomId")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := c.roomService.GetHistory(userID, roomID, query)
+	if err != nil {
+		http.Error(w, err.Error(), roomErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+func roomErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, websocket.ErrRoomNotFound), errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotRoomMember), errors.Is(err, service.ErrNotRoomOwner),
+		errors.Is(err, service.ErrCannotAddMember):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,23 @@
+package hub
+
+// BrokerEvent is an event published by one server instance for the
+// connections of UserIDs held by every other instance. An event with
+// SuspendUntil set carries no Data; it tells every instance to close the
+// users' connections and refuse new ones until then (unix seconds).
+type BrokerEvent struct {
+	Origin       string                 `json:"origin"`
+	UserIDs      []int64                `json:"user_ids"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	SuspendUntil int64                  `json:"suspend_until,omitempty"`
+}
+
+// Broker carries hub events between server instances, so a user connected
+// to one instance hears about messages, receipts, presence and typing
+// produced on another.
+type Broker interface {
+	Publish(event BrokerEvent) error
+	// Subscribe starts delivering events published by any instance,
+	// including this one, to deliver.
+	Subscribe(deliver func(BrokerEvent)) error
+	Close() error
+}
@@ -0,0 +1,228 @@
+package hub
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait bounds a single write, so a stalled peer cannot pin the
+	// write pump forever.
+	writeWait = 10 * time.Second
+	// pongWait is how long a connection may stay silent, pongs included,
+	// before it is considered dead.
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait so a healthy peer always
+	// has a pong in flight.
+	pingPeriod = pongWait * 9 / 10
+	// sendBufferSize is how many frames may queue up for a connection. A
+	// client that falls this far behind is disconnected rather than
+	// slowing down everyone sending to it.
+	sendBufferSize = 256
+	// maxHeldFrames bounds the live frames held back while a replay runs.
+	maxHeldFrames = 1024
+)
+
+// Client is one WebSocket connection of a user. gorilla/websocket allows a
+// single writer per connection, so every write goes through the send queue
+// and is performed by WritePump.
+type Client struct {
+	UserID  int64
+	Version int
+
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// While replaying, live frames are held in held instead of the send
+	// queue, which the replay fills on purpose.
+	mu        sync.Mutex
+	replaying bool
+	held      [][]byte
+}
+
+func NewClient(userID int64, conn *websocket.Conn, version int) *Client {
+	return &Client{
+		UserID:  userID,
+		Version: version,
+		conn:    conn,
+		send:    make(chan []byte, sendBufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Send queues the event data for this connection, encoded for its protocol
+// version. If the queue is full the client is disconnected and Send
+// reports false.
+func (c *Client) Send(data map[string]interface{}) bool {
+	return c.SendFrame(Encode(c.Version, data))
+}
+
+// SendFrame queues an already shaped frame, such as an ack, as is.
+func (c *Client) SendFrame(frame interface{}) bool {
+	raw, err := json.Marshal(frame)
+	if err != nil {
+		return false
+	}
+
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	c.mu.Lock()
+	if c.replaying {
+		if len(c.held) >= maxHeldFrames {
+			c.mu.Unlock()
+			c.Close()
+			return false
+		}
+		c.held = append(c.held, raw)
+		c.mu.Unlock()
+		return true
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.send <- raw:
+		return true
+	default:
+		c.Close()
+		return false
+	}
+}
+
+// sendWait is Send for bulk writes such as a replay, which would overflow
+// the queue on purpose: it waits for room instead of dropping the client.
+func (c *Client) sendWait(data map[string]interface{}) bool {
+	raw, err := json.Marshal(Encode(c.Version, data))
+	if err != nil {
+		return false
+	}
+	return c.queueWait(raw)
+}
+
+func (c *Client) queueWait(raw []byte) bool {
+	select {
+	case c.send <- raw:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// BeginReplay holds back live frames until the replay started by
+// Hub.ReplayMessages is over, so they neither interleave with it nor get
+// the client dropped for a queue the replay filled. It must be called
+// before the client is registered with the hub.
+func (c *Client) BeginReplay() {
+	c.mu.Lock()
+	c.replaying = true
+	c.mu.Unlock()
+}
+
+// endReplay queues the frames held back during the replay, waiting for
+// room like the replay did, and then lets live frames through again.
+func (c *Client) endReplay() {
+	for {
+		c.mu.Lock()
+		held := c.held
+		c.held = nil
+		if len(held) == 0 {
+			c.replaying = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		for _, raw := range held {
+			if !c.queueWait(raw) {
+				return
+			}
+		}
+	}
+}
+
+// Close stops the write pump, which then closes the connection and so ends
+// the read loop too. It is safe to call more than once.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// WritePump writes queued frames and periodic pings until the client is
+// closed or a write fails. It must run in its own goroutine.
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case raw := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, raw); err != nil {
+				c.Close()
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close()
+				return
+			}
+
+		case <-c.done:
+			c.flush()
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(writeWait))
+			return
+		}
+	}
+}
+
+// flush writes what is still queued, such as the error frame explaining a
+// disconnect, without waiting for more.
+func (c *Client) flush() {
+	for {
+		select {
+		case raw := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, raw); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// ReadPump passes every incoming frame to handle until the connection fails
+// or goes silent for longer than pongWait, then closes the client.
+func (c *Client) ReadPump(handle func(data []byte)) {
+	defer c.Close()
+
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		handle(data)
+	}
+}
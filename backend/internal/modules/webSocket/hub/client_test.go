@@ -0,0 +1,138 @@
+package hub
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	dto "lilyChat/internal/modules/dto"
+)
+
+// queuedTypes empties the send queue of c and returns the type of every
+// frame that was in it, in order.
+func queuedTypes(t *testing.T, c *Client) []string {
+	t.Helper()
+
+	types := []string{}
+	for {
+		select {
+		case raw := <-c.send:
+			var frame struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(raw, &frame); err != nil {
+				t.Fatal(err)
+			}
+			types = append(types, frame.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func isClosed(c *Client) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func event(eventType string) map[string]interface{} {
+	return map[string]interface{}{"type": eventType}
+}
+
+func TestClientSend(t *testing.T) {
+	tests := []struct {
+		name       string
+		frames     int
+		wantOK     bool
+		wantClosed bool
+	}{
+		{"queues frames", 3, true, false},
+		{"fills the queue", sendBufferSize, true, false},
+		{"disconnects a client that falls behind", sendBufferSize + 1, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(1, nil, dto.ProtocolLegacy)
+
+			ok := true
+			for i := 0; i < tt.frames; i++ {
+				ok = c.Send(event("message"))
+			}
+			if ok != tt.wantOK {
+				t.Errorf("last Send = %v, want %v", ok, tt.wantOK)
+			}
+			if isClosed(c) != tt.wantClosed {
+				t.Errorf("closed = %v, want %v", isClosed(c), tt.wantClosed)
+			}
+		})
+	}
+}
+
+func TestClientSendAfterClose(t *testing.T) {
+	c := NewClient(1, nil, dto.ProtocolLegacy)
+	c.Close()
+	c.Close()
+
+	if c.Send(event("message")) {
+		t.Error("Send on a closed client reported success")
+	}
+}
+
+func TestClientHoldsLiveFramesDuringReplay(t *testing.T) {
+	c := NewClient(1, nil, dto.ProtocolLegacy)
+	c.BeginReplay()
+
+	if !c.Send(event("live-1")) || !c.Send(event("live-2")) {
+		t.Fatal("Send during replay failed")
+	}
+	if got := queuedTypes(t, c); len(got) != 0 {
+		t.Fatalf("live frames reached the queue during replay: %v", got)
+	}
+
+	c.sendWait(event("replayed"))
+	c.endReplay()
+	c.Send(event("live-3"))
+
+	want := []string{"replayed", "live-1", "live-2", "live-3"}
+	if got := queuedTypes(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("queue = %v, want %v", got, want)
+	}
+}
+
+func TestClientDisconnectsWhenTooManyFramesAreHeld(t *testing.T) {
+	c := NewClient(1, nil, dto.ProtocolLegacy)
+	c.BeginReplay()
+
+	for i := 0; i < maxHeldFrames; i++ {
+		if !c.Send(event("live")) {
+			t.Fatalf("Send %d failed before the limit", i)
+		}
+	}
+	if c.Send(event("live")) {
+		t.Error("Send past maxHeldFrames reported success")
+	}
+	if !isClosed(c) {
+		t.Error("client was not closed")
+	}
+}
+
+func TestReplayMessagesSendsHeldEventsAfterTheReplay(t *testing.T) {
+	h := NewHub()
+	c := NewClient(1, nil, dto.ProtocolLegacy)
+	c.BeginReplay()
+	h.Register(c)
+
+	h.SendToUser(1, event("receipt"))
+	h.ReplayMessages(c, []*dto.Message{{ID: 1, SenderID: 2, ReceiverID: 1}, {ID: 2, SenderID: 2, ReceiverID: 1}}, 2, true)
+	h.SendToUser(1, event("typing"))
+
+	want := []string{"message", "message", "synced", "receipt", "typing"}
+	if got := queuedTypes(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("queue = %v, want %v", got, want)
+	}
+}
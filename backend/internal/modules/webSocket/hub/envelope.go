@@ -0,0 +1,42 @@
+package hub
+
+import (
+	"encoding/json"
+
+	dto "lilyChat/internal/modules/dto"
+)
+
+// Encode shapes an event for a connection speaking the given protocol
+// version. Legacy clients get data as is, a flat object with a "type" key;
+// versioned clients get it wrapped in a dto.Envelope with the remaining
+// keys as the payload.
+func Encode(version int, data map[string]interface{}) interface{} {
+	if version == dto.ProtocolLegacy {
+		return data
+	}
+
+	eventType, _ := data["type"].(string)
+	payload := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if k != "type" {
+			payload[k] = v
+		}
+	}
+	return NewEnvelope(eventType, "", payload)
+}
+
+// NewEnvelope builds a versioned frame; id correlates a reply with the
+// request it answers and is empty for server-initiated events.
+func NewEnvelope(frameType, id string, payload interface{}) dto.Envelope {
+	env := dto.Envelope{
+		V:    dto.ProtocolV1,
+		Type: frameType,
+		ID:   id,
+	}
+	if payload != nil {
+		if raw, err := json.Marshal(payload); err == nil {
+			env.Payload = raw
+		}
+	}
+	return env
+}
@@ -0,0 +1,85 @@
+package hub
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	dto "lilyChat/internal/modules/dto"
+)
+
+func TestEncode(t *testing.T) {
+	data := map[string]interface{}{
+		"type":    "message",
+		"id":      float64(7),
+		"text":    "hi",
+		"room_id": float64(0),
+	}
+
+	tests := []struct {
+		name    string
+		version int
+		want    string
+	}{
+		{"legacy frames stay flat", dto.ProtocolLegacy,
+			`{"id":7,"room_id":0,"text":"hi","type":"message"}`},
+		{"v1 frames wrap the rest in a payload", dto.ProtocolV1,
+			`{"v":1,"type":"message","payload":{"id":7,"room_id":0,"text":"hi"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(Encode(tt.version, data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != tt.want {
+				t.Errorf("got %s, want %s", raw, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEnvelopeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		frameType   string
+		id          string
+		payload     interface{}
+		wantPayload map[string]interface{}
+	}{
+		{"ack with result", dto.FrameAck, "c-1", map[string]int{"id": 5}, map[string]interface{}{"id": float64(5)}},
+		{"ack without result", dto.FrameAck, "c-2", nil, nil},
+		{"error", dto.FrameError, "c-3", dto.ErrorPayload{Code: dto.ErrCodeNotFound, Message: "gone"},
+			map[string]interface{}{"code": dto.ErrCodeNotFound, "message": "gone"}},
+		{"event without id", "presence", "", map[string]bool{"online": true}, map[string]interface{}{"online": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(NewEnvelope(tt.frameType, tt.id, tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var env dto.Envelope
+			if err := json.Unmarshal(raw, &env); err != nil {
+				t.Fatal(err)
+			}
+			if env.V != dto.ProtocolV1 || env.Type != tt.frameType || env.ID != tt.id {
+				t.Errorf("got v=%d type=%q id=%q, want v=%d type=%q id=%q",
+					env.V, env.Type, env.ID, dto.ProtocolV1, tt.frameType, tt.id)
+			}
+
+			var payload map[string]interface{}
+			if len(env.Payload) > 0 {
+				if err := json.Unmarshal(env.Payload, &payload); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(payload, tt.wantPayload) {
+				t.Errorf("payload = %v, want %v", payload, tt.wantPayload)
+			}
+		})
+	}
+}
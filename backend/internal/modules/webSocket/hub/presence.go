@@ -0,0 +1,25 @@
+package hub
+
+import "time"
+
+// presenceHeartbeat is how often the hub refreshes its entries in the
+// PresenceStore. Stores must keep entries alive for several periods.
+const presenceHeartbeat = 30 * time.Second
+
+// PresenceStore tracks which users are connected to which server instance,
+// so online state is shared by every instance rather than local to one.
+// The hub only calls Add for a user's first connection on this instance
+// and Remove for their last one.
+type PresenceStore interface {
+	// Add records that userID has connections on instanceID and reports
+	// whether no other instance has any, i.e. the user just came online.
+	Add(instanceID string, userID int64) (first bool, err error)
+	// Remove forgets userID on instanceID and reports whether no other
+	// instance has connections of theirs left, i.e. the user went offline.
+	Remove(instanceID string, userID int64) (last bool, err error)
+	// Online returns those of userIDs connected to any live instance.
+	Online(userIDs []int64) (map[int64]bool, error)
+	// Heartbeat keeps the entries of instanceID alive and drops those of
+	// instances that stopped sending heartbeats.
+	Heartbeat(instanceID string) error
+}
@@ -0,0 +1,99 @@
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TypingStart = "start"
+	TypingStop  = "stop"
+)
+
+// typingTimeout is how long a "start" stays valid without being refreshed.
+// Clients are expected to resend "start" while the user keeps typing.
+const typingTimeout = 6 * time.Second
+
+type typingKey struct {
+	from int64
+	to   int64
+}
+
+// typingTracker remembers who is currently typing to whom so the hub can
+// send the partner a "stop" on its own when a client goes quiet or drops.
+type typingTracker struct {
+	timers  map[typingKey]*time.Timer
+	mu      sync.Mutex
+	timeout time.Duration
+}
+
+func newTypingTracker() *typingTracker {
+	return &typingTracker{
+		timers:  make(map[typingKey]*time.Timer),
+		timeout: typingTimeout,
+	}
+}
+
+// start arms or refreshes the expiry for from->to and reports whether this
+// is a new typing session the partner has not been told about yet.
+func (t *typingTracker) start(from, to int64, expire func()) bool {
+	key := typingKey{from: from, to: to}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if timer, ok := t.timers[key]; ok {
+		timer.Reset(t.timeout)
+		return false
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(t.timeout, func() {
+		t.mu.Lock()
+		current, ok := t.timers[key]
+		if ok && current == timer {
+			delete(t.timers, key)
+		}
+		t.mu.Unlock()
+
+		if ok && current == timer {
+			expire()
+		}
+	})
+	t.timers[key] = timer
+	return true
+}
+
+// stop forgets from->to and reports whether it was active.
+func (t *typingTracker) stop(from, to int64) bool {
+	key := typingKey{from: from, to: to}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timer, ok := t.timers[key]
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	delete(t.timers, key)
+	return true
+}
+
+// clear forgets everything from is typing and returns the partners that
+// still think from is typing.
+func (t *typingTracker) clear(from int64) []int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	partners := []int64{}
+	for key, timer := range t.timers {
+		if key.from != from {
+			continue
+		}
+		timer.Stop()
+		delete(t.timers, key)
+		partners = append(partners, key.to)
+	}
+	return partners
+}
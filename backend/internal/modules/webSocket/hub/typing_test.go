@@ -0,0 +1,121 @@
+package hub
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTypingTracker(t *testing.T) {
+	type call struct {
+		op       string // start, stop
+		from, to int64
+		want     bool
+	}
+
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{"first start is new", []call{
+			{"start", 1, 2, true},
+		}},
+		{"repeated start only refreshes", []call{
+			{"start", 1, 2, true},
+			{"start", 1, 2, false},
+		}},
+		{"directions are separate", []call{
+			{"start", 1, 2, true},
+			{"start", 2, 1, true},
+		}},
+		{"stop reports an active session", []call{
+			{"start", 1, 2, true},
+			{"stop", 1, 2, true},
+			{"stop", 1, 2, false},
+		}},
+		{"stop without start", []call{
+			{"stop", 1, 2, false},
+		}},
+		{"start after stop is new again", []call{
+			{"start", 1, 2, true},
+			{"stop", 1, 2, true},
+			{"start", 1, 2, true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTypingTracker()
+			defer tracker.clear(1)
+			defer tracker.clear(2)
+
+			for i, c := range tt.calls {
+				var got bool
+				if c.op == "start" {
+					got = tracker.start(c.from, c.to, func() {})
+				} else {
+					got = tracker.stop(c.from, c.to)
+				}
+				if got != c.want {
+					t.Errorf("call %d: %s(%d, %d) = %v, want %v", i, c.op, c.from, c.to, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTypingTrackerClear(t *testing.T) {
+	tracker := newTypingTracker()
+	tracker.start(1, 2, func() {})
+	tracker.start(1, 3, func() {})
+	tracker.start(4, 1, func() {})
+
+	partners := tracker.clear(1)
+	sort.Slice(partners, func(i, j int) bool { return partners[i] < partners[j] })
+	if want := []int64{2, 3}; !reflect.DeepEqual(partners, want) {
+		t.Errorf("clear(1) = %v, want %v", partners, want)
+	}
+	if tracker.stop(1, 2) {
+		t.Error("1->2 still active after clear")
+	}
+	if !tracker.stop(4, 1) {
+		t.Error("clear(1) dropped 4->1, which someone else is typing")
+	}
+}
+
+func TestTypingTrackerExpires(t *testing.T) {
+	tracker := newTypingTracker()
+	tracker.timeout = 20 * time.Millisecond
+
+	expired := make(chan struct{}, 1)
+	tracker.start(1, 2, func() { expired <- struct{}{} })
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("typing did not expire")
+	}
+	if tracker.stop(1, 2) {
+		t.Error("expired session is still active")
+	}
+	if !tracker.start(1, 2, func() {}) {
+		t.Error("start after expiry is not new")
+	}
+	tracker.clear(1)
+}
+
+func TestTypingTrackerStopPreventsExpiry(t *testing.T) {
+	tracker := newTypingTracker()
+	tracker.timeout = 20 * time.Millisecond
+
+	expired := make(chan struct{}, 1)
+	tracker.start(1, 2, func() { expired <- struct{}{} })
+	tracker.stop(1, 2)
+
+	select {
+	case <-expired:
+		t.Error("stopped session expired")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
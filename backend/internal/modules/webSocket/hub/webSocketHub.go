@@ -1,69 +1,494 @@
 package hub
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
 	"sync"
+	"time"
 
 	dto "lilyChat/internal/modules/dto"
-	"github.com/gorilla/websocket"
 )
 
+// presenceLockStripes is the number of locks user presence changes are
+// spread over.
+const presenceLockStripes = 64
+
+// Hub tracks every open connection per user; one user may be connected from
+// several tabs or devices at once. With a Broker attached, events are also
+// relayed to users connected to other server instances, and with a
+// PresenceStore attached, online state covers every instance.
 type Hub struct {
-	clients map[int64]*websocket.Conn
-	mu      sync.Mutex
+	clients    map[int64]map[*Client]struct{}
+	mu         sync.Mutex
+	typing     *typingTracker
+	instanceID string
+	broker     Broker
+	presence   PresenceStore
+	// suspended maps users who may not connect to the time they may again.
+	suspended map[int64]time.Time
+	// presenceLocks keep a user's local connection change and the matching
+	// PresenceStore update together, so a quick reconnect cannot be undone
+	// by the Remove of the connection it replaced.
+	presenceLocks [presenceLockStripes]sync.Mutex
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		clients: make(map[int64]*websocket.Conn),
+		clients:    make(map[int64]map[*Client]struct{}),
+		suspended:  make(map[int64]time.Time),
+		typing:     newTypingTracker(),
+		instanceID: newInstanceID(),
 	}
 }
 
-func (h *Hub) Register(userID int64, conn *websocket.Conn) {
+// UseBroker attaches broker and starts relaying its events to local
+// connections. It must be called before the hub starts serving clients.
+func (h *Hub) UseBroker(broker Broker) error {
+	h.broker = broker
+	return broker.Subscribe(func(event BrokerEvent) {
+		if event.Origin == h.instanceID {
+			return
+		}
+		if event.SuspendUntil != 0 {
+			for _, userID := range event.UserIDs {
+				h.suspend(userID, time.Unix(event.SuspendUntil, 0))
+			}
+			return
+		}
+		h.deliver(event.UserIDs, event.Data)
+	})
+}
+
+// UsePresence shares online state through store and keeps this instance's
+// entries alive. Like UseBroker, it must be called before the hub starts
+// serving clients.
+func (h *Hub) UsePresence(store PresenceStore) {
+	h.presence = store
+
+	go func() {
+		ticker := time.NewTicker(presenceHeartbeat)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := store.Heartbeat(h.instanceID); err != nil {
+				log.Printf("[Hub] presence heartbeat failed: %v", err)
+			}
+		}
+	}()
+}
+
+// Suspend closes every connection of userID, on all instances, and makes
+// IsSuspended report the user until the given time.
+func (h *Hub) Suspend(userID int64, until time.Time) {
+	h.suspend(userID, until)
+
+	if h.broker != nil {
+		err := h.broker.Publish(BrokerEvent{
+			Origin:       h.instanceID,
+			UserIDs:      []int64{userID},
+			SuspendUntil: until.Unix(),
+		})
+		if err != nil {
+			log.Printf("[Hub] cannot publish suspension of user %d: %v", userID, err)
+		}
+	}
+}
+
+func (h *Hub) suspend(userID int64, until time.Time) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.clients[userID] = conn
+	h.suspended[userID] = until
+	h.mu.Unlock()
+
+	for _, client := range h.clientsFor(userID) {
+		client.Close()
+	}
 }
 
-func (h *Hub) Unregister(userID int64) {
+// IsSuspended reports whether userID is barred from connecting, and until
+// when.
+func (h *Hub) IsSuspended(userID int64) (time.Time, bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	delete(h.clients, userID)
+
+	until, ok := h.suspended[userID]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !time.Now().Before(until) {
+		delete(h.suspended, userID)
+		return time.Time{}, false
+	}
+	return until, true
 }
 
-func (h *Hub) SendMessage(msg *dto.Message) error {
+func (h *Hub) presenceLock(userID int64) *sync.Mutex {
+	return &h.presenceLocks[uint64(userID)%presenceLockStripes]
+}
+
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Register adds client to its user's connections and reports whether it is
+// the user's first one on any instance, i.e. whether the user just came
+// online.
+func (h *Hub) Register(client *Client) bool {
+	lock := h.presenceLock(client.UserID)
+	lock.Lock()
+	defer lock.Unlock()
+
 	h.mu.Lock()
-	receiverConn, receiverOk := h.clients[msg.ReceiverID]
-	senderConn, senderOk := h.clients[msg.SenderID]
+	conns, ok := h.clients[client.UserID]
+	if !ok {
+		conns = make(map[*Client]struct{})
+		h.clients[client.UserID] = conns
+	}
+	conns[client] = struct{}{}
 	h.mu.Unlock()
-	
-	messageData := map[string]interface{}{
-		"type":        "message",
-		"id":          msg.ID,
-		"sender_id":   msg.SenderID,
-		"receiver_id": msg.ReceiverID,
-		"text":        msg.Text,
-		"created_at": msg.CreatedAt,
+
+	if ok || h.presence == nil {
+		return !ok
 	}
+	first, err := h.presence.Add(h.instanceID, client.UserID)
+	if err != nil {
+		log.Printf("[Hub] cannot record presence of user %d: %v", client.UserID, err)
+		return true
+	}
+	return first
+}
+
+// Unregister removes and closes only client and reports whether it was the
+// user's last connection on any instance, i.e. whether the user just went
+// offline.
+func (h *Hub) Unregister(client *Client) bool {
+	client.Close()
 
-	conns := []*websocket.Conn{}
-	
-	if senderOk {
-		conns = append(conns, senderConn)
+	userID := client.UserID
+	lock := h.presenceLock(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	h.mu.Lock()
+	conns, ok := h.clients[userID]
+	if !ok {
+		h.mu.Unlock()
+		return false
+	}
+	if _, ok := conns[client]; !ok {
+		h.mu.Unlock()
+		return false
+	}
+	delete(conns, client)
+	last := len(conns) == 0
+	if last {
+		delete(h.clients, userID)
+	}
+	h.mu.Unlock()
+
+	if !last {
+		return false
 	}
 
-	if receiverOk && receiverConn != senderConn {
-		conns = append(conns, receiverConn)
+	for _, partnerID := range h.typing.clear(userID) {
+		h.sendTyping(partnerID, userID, TypingStop)
 	}
 
-	for _, conn := range conns {
-    	if err := conn.WriteJSON(messageData); err != nil {
-    	}
+	if h.presence == nil {
+		return true
 	}
+	last, err := h.presence.Remove(h.instanceID, userID)
+	if err != nil {
+		log.Printf("[Hub] cannot clear presence of user %d: %v", userID, err)
+		return true
+	}
+	return last
+}
 
-	
+func (h *Hub) SendMessage(msg *dto.Message) error {
+	h.SendToUsers([]int64{msg.SenderID, msg.ReceiverID}, messageEvent(msg))
 	return nil
 }
 
+// SendRoomMessage fans a room message out to every connected member.
+func (h *Hub) SendRoomMessage(memberIDs []int64, msg *dto.Message) {
+	h.SendToUsers(memberIDs, messageEvent(msg))
+}
+
+// ResendMessage pushes an already delivered msg to userID again, e.g. to
+// confirm a retried send to its sender.
+func (h *Hub) ResendMessage(userID int64, msg *dto.Message) {
+	h.SendToUser(userID, messageEvent(msg))
+}
+
+// SendHeldMessage pushes msg, which is held in a message request, to its
+// sender only; the receiver hears about it through SendMessageRequest.
+func (h *Hub) SendHeldMessage(msg *dto.Message) {
+	h.SendToUser(msg.SenderID, messageEvent(msg))
+}
+
+// SendMessageRequest tells userIDs that the message request from senderID
+// was "received" (with the new msg), "accepted" or "rejected".
+func (h *Hub) SendMessageRequest(userIDs []int64, senderID int64, action string, msg *dto.Message) {
+	data := map[string]interface{}{
+		"type":    "message_request",
+		"action":  action,
+		"user_id": senderID,
+	}
+	if msg != nil {
+		data["message"] = messageEvent(msg)
+	}
+	h.SendToUsers(userIDs, data)
+}
+
+// SendMessageUpdated pushes the edited version of msg so open clients can
+// replace it in place.
+func (h *Hub) SendMessageUpdated(userIDs []int64, msg *dto.Message) {
+	data := messageEvent(msg)
+	data["type"] = "message_updated"
+	data["edited_at"] = msg.EditedAt
+	h.SendToUsers(userIDs, data)
+}
+
+// SendMessageDeleted tells clients to drop msg; scope says whether it was
+// hidden for one user ("me") or removed for everyone.
+func (h *Hub) SendMessageDeleted(userIDs []int64, msg *dto.Message, scope string) {
+	data := map[string]interface{}{
+		"type":      "message_deleted",
+		"id":        msg.ID,
+		"sender_id": msg.SenderID,
+		"scope":     scope,
+	}
+	if msg.RoomID != 0 {
+		data["room_id"] = msg.RoomID
+	} else {
+		data["receiver_id"] = msg.ReceiverID
+	}
+	h.SendToUsers(userIDs, data)
+}
+
+// SendReaction tells userIDs that userID "added" or "removed" emoji on msg.
+func (h *Hub) SendReaction(userIDs []int64, msg *dto.Message, userID int64, emoji, action string) {
+	h.SendToUsers(userIDs, map[string]interface{}{
+		"type":       "reaction",
+		"message_id": msg.ID,
+		"user_id":    userID,
+		"emoji":      emoji,
+		"action":     action,
+	})
+}
+
+// ReplayMessages writes msgs, oldest first, to a single connection that is
+// catching up after a reconnect, then a "synced" event carrying the last ID
+// replayed. complete is false when the server stopped early and the client
+// should fetch the rest through the history API.
+//
+// The client must have been put in replay mode with BeginReplay; live
+// events held back meanwhile are sent once the replay is done.
+func (h *Hub) ReplayMessages(client *Client, msgs []*dto.Message, lastID int64, complete bool) {
+	defer client.endReplay()
+
+	for _, msg := range msgs {
+		if !client.sendWait(messageEvent(msg)) {
+			return
+		}
+	}
+	client.sendWait(map[string]interface{}{
+		"type":            "synced",
+		"last_message_id": lastID,
+		"complete":        complete,
+	})
+}
+
+func messageEvent(msg *dto.Message) map[string]interface{} {
+	data := map[string]interface{}{
+		"type":       "message",
+		"id":         msg.ID,
+		"sender_id":  msg.SenderID,
+		"text":       msg.Text,
+		"created_at": msg.CreatedAt,
+	}
+	if msg.RoomID != 0 {
+		data["room_id"] = msg.RoomID
+	} else {
+		data["receiver_id"] = msg.ReceiverID
+	}
+	if msg.ClientMsgID != "" {
+		data["client_msg_id"] = msg.ClientMsgID
+	}
+	if msg.ReplyTo != nil {
+		data["reply_to"] = msg.ReplyTo
+	}
+	if len(msg.Attachments) > 0 {
+		data["attachments"] = msg.Attachments
+	}
+	return data
+}
+
+func (h *Hub) SendRoomCreated(memberIDs []int64, room *dto.Room) {
+	h.SendToUsers(memberIDs, map[string]interface{}{
+		"type":     "room_created",
+		"room_id":  room.ID,
+		"name":     room.Name,
+		"owner_id": room.OwnerID,
+	})
+}
+
+// SendRoomMember tells memberIDs that userID was "added" to or "removed"
+// from roomID.
+func (h *Hub) SendRoomMember(memberIDs []int64, roomID, userID int64, action string) {
+	h.SendToUsers(memberIDs, map[string]interface{}{
+		"type":    "room_member",
+		"room_id": roomID,
+		"user_id": userID,
+		"action":  action,
+	})
+}
+
+// SendContactRequest tells userIDs that req was "received", "accepted" or
+// "cancelled".
+func (h *Hub) SendContactRequest(userIDs []int64, req *dto.ContactRequest, action string) {
+	h.SendToUsers(userIDs, map[string]interface{}{
+		"type":    "contact_request",
+		"action":  action,
+		"request": req,
+	})
+}
+
+// SendNotification alerts userID about n. Unlike the event that delivered
+// the message, it is only sent when the user wants to be alerted.
+func (h *Hub) SendNotification(userID int64, n *dto.Notification) {
+	h.SendToUser(userID, map[string]interface{}{
+		"type":         "notification",
+		"notification": n,
+	})
+}
+
+// SendReceipt tells the original sender that readerID reached the given
+// delivery status ("delivered" or "read") for messageIDs.
+func (h *Hub) SendReceipt(senderID, readerID int64, status string, messageIDs []int64, at int64) {
+	h.SendToUser(senderID, map[string]interface{}{
+		"type":        "receipt",
+		"status":      status,
+		"user_id":     readerID,
+		"message_ids": messageIDs,
+		"at":          at,
+	})
+}
+
+func (h *Hub) IsOnline(userID int64) bool {
+	return h.Online([]int64{userID})[userID]
+}
+
+// Online returns those of userIDs with a connection to any instance. If the
+// PresenceStore cannot be reached, it falls back to local connections.
+func (h *Hub) Online(userIDs []int64) map[int64]bool {
+	if h.presence != nil {
+		online, err := h.presence.Online(userIDs)
+		if err == nil {
+			return online
+		}
+		log.Printf("[Hub] cannot read presence: %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	online := make(map[int64]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if _, ok := h.clients[userID]; ok {
+			online[userID] = true
+		}
+	}
+	return online
+}
+
+// SendPresence tells each of partnerIDs that userID came online or went
+// offline.
+func (h *Hub) SendPresence(partnerIDs []int64, userID int64, online bool, lastSeen int64) {
+	data := map[string]interface{}{
+		"type":      "presence",
+		"user_id":   userID,
+		"online":    online,
+		"last_seen": lastSeen,
+	}
+	h.SendToUsers(partnerIDs, data)
+}
+
+// StartTyping relays a typing indicator from senderID to receiverID. Nothing
+// is persisted; the indicator is withdrawn automatically after typingTimeout
+// unless the sender refreshes it.
+func (h *Hub) StartTyping(senderID, receiverID int64) {
+	isNew := h.typing.start(senderID, receiverID, func() {
+		h.sendTyping(receiverID, senderID, TypingStop)
+	})
+	if isNew {
+		h.sendTyping(receiverID, senderID, TypingStart)
+	}
+}
+
+func (h *Hub) StopTyping(senderID, receiverID int64) {
+	if h.typing.stop(senderID, receiverID) {
+		h.sendTyping(receiverID, senderID, TypingStop)
+	}
+}
+
+func (h *Hub) sendTyping(receiverID, senderID int64, state string) {
+	h.SendToUser(receiverID, map[string]interface{}{
+		"type":    "typing",
+		"user_id": senderID,
+		"state":   state,
+	})
+}
+
+func (h *Hub) SendToUser(userID int64, data map[string]interface{}) {
+	h.SendToUsers([]int64{userID}, data)
+}
+
+// SendToUsers queues the event data, whose "type" key names the event, on
+// every connection of userIDs, on this instance and, through the broker, on
+// the others. It never blocks on a slow connection.
+func (h *Hub) SendToUsers(userIDs []int64, data map[string]interface{}) {
+	h.deliver(userIDs, data)
+
+	if h.broker != nil {
+		err := h.broker.Publish(BrokerEvent{
+			Origin:  h.instanceID,
+			UserIDs: userIDs,
+			Data:    data,
+		})
+		if err != nil {
+			log.Printf("[Hub] cannot publish %v event: %v", data["type"], err)
+		}
+	}
+}
+
+func (h *Hub) deliver(userIDs []int64, data map[string]interface{}) {
+	for _, client := range h.clientsFor(userIDs...) {
+		client.Send(data)
+	}
+}
+
+// clientsFor snapshots the connections of the given users, listing each
+// connection once even if a user ID repeats.
+func (h *Hub) clientsFor(userIDs ...int64) []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := make(map[int64]bool, len(userIDs))
+	clients := []*Client{}
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		for client := range h.clients[userID] {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
 func (h *Hub) getClientIDs() []int64 {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -0,0 +1,52 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	dto "lilyChat/internal/modules/dto"
+)
+
+func TestSuspend(t *testing.T) {
+	h := NewHub()
+	first := NewClient(1, nil, dto.ProtocolLegacy)
+	second := NewClient(1, nil, dto.ProtocolV1)
+	other := NewClient(2, nil, dto.ProtocolLegacy)
+	for _, c := range []*Client{first, second, other} {
+		h.Register(c)
+	}
+
+	until := time.Now().Add(time.Minute)
+	h.Suspend(1, until)
+
+	if !isClosed(first) || !isClosed(second) {
+		t.Error("not every connection of the suspended user was closed")
+	}
+	if isClosed(other) {
+		t.Error("another user's connection was closed")
+	}
+
+	tests := []struct {
+		userID    int64
+		wantUntil time.Time
+		want      bool
+	}{
+		{1, until, true},
+		{2, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		gotUntil, got := h.IsSuspended(tt.userID)
+		if got != tt.want || !gotUntil.Equal(tt.wantUntil) {
+			t.Errorf("IsSuspended(%d) = %v, %v; want %v, %v", tt.userID, gotUntil, got, tt.wantUntil, tt.want)
+		}
+	}
+}
+
+func TestSuspensionEnds(t *testing.T) {
+	h := NewHub()
+	h.Suspend(1, time.Now().Add(-time.Second))
+
+	if _, suspended := h.IsSuspended(1); suspended {
+		t.Error("user is still suspended after the penalty ended")
+	}
+}
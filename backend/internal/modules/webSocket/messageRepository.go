@@ -1,54 +1,556 @@
 package websocket
 
 import (
+	"database/sql"
 	"errors"
 	dto "lilyChat/internal/modules/dto"
-	"sync"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrMessageNotFound       = errors.New("message not found")
+	ErrAttachmentUnavailable = errors.New("attachment not found or already used")
+	ErrDuplicateMessage      = errors.New("message already sent")
 )
 
 type MessageRepository interface {
+	// Save stores msg and claims the attachments listed in
+	// msg.AttachmentIDs for it, filling in msg.ID and msg.Attachments. If the
+	// sender already sent a message with the same ClientMsgID, nothing is
+	// stored: msg is overwritten with that message and ErrDuplicateMessage
+	// is returned.
 	Save(msg *dto.Message) error
+	Get(messageID int64) (*dto.Message, error)
+	// UpdateText replaces the text of a message, keeping the previous text
+	// as a revision, and returns the updated message.
+	UpdateText(messageID int64, text string, editedAt int64) (*dto.Message, error)
+	GetRevisions(messageID int64) ([]*dto.MessageRevision, error)
+
+	GetConversation(user1ID, user2ID int64, query dto.HistoryQuery) ([]*dto.Message, error)
+	GetRoomHistory(roomID, viewerID int64, query dto.HistoryQuery) ([]*dto.Message, error)
+	// GetSince returns up to limit messages visible to userID with an ID
+	// above afterID, oldest first.
+	GetSince(userID, afterID int64, limit int) ([]*dto.Message, error)
+	// Search runs a full-text search over the messages userID can see.
+	Search(userID int64, query dto.SearchQuery) ([]*dto.SearchResult, error)
+
+	// Hide removes a message from userID's own history only.
+	Hide(messageID, userID, at int64) error
+	// DeleteForEveryone tombstones a message and discards its content.
+	DeleteForEveryone(messageID, at int64) error
 
-	GetConversation(user1ID, user2ID int64) ([]*dto.Message, error)
+	// AddReaction and RemoveReaction report whether anything changed, so a
+	// repeated add or remove is a no-op.
+	AddReaction(messageID, userID int64, emoji string, at int64) (bool, error)
+	RemoveReaction(messageID, userID int64, emoji string) (bool, error)
+	GetInbox(userID int64) ([]*dto.Conversation, error)
+
+	// MarkDelivered stamps a single message addressed to receiverID and
+	// reports its sender; ok is false when there was nothing to update.
+	MarkDelivered(receiverID, messageID, at int64) (senderID int64, ok bool, err error)
+	// MarkRead stamps every unread message from peerID to readerID up to and
+	// including upToID and returns the IDs it changed.
+	MarkRead(readerID, peerID, upToID, at int64) ([]int64, error)
+
+	// GetPartnerIDs lists everyone userID has exchanged messages with.
+	GetPartnerIDs(userID int64) ([]int64, error)
 }
 
-type InMemoryMessageRepo struct {
-	messages []*dto.Message
-	mu       sync.Mutex
-	nextID   int64
+type PostgresMessageRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewPostgresMessageRepo(sqlDB *sql.DB) *PostgresMessageRepo {
+	return &PostgresMessageRepo{
+		sqlDB: sqlDB,
+	}
 }
 
-func NewInMemoryMessageRepo() *InMemoryMessageRepo {
-	return &InMemoryMessageRepo{
-		messages: make([]*dto.Message, 0),
-		nextID:   1,
+func (r *PostgresMessageRepo) Save(msg *dto.Message) error {
+	tx, err := r.sqlDB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(insertMessage,
+		msg.SenderID,
+		nullableID(msg.ReceiverID),
+		nullableID(msg.RoomID),
+		nullableID(msg.ReplyToID),
+		msg.Text,
+		msg.CreatedAt,
+		sql.NullString{String: msg.ClientMsgID, Valid: msg.ClientMsgID != ""},
+		msg.IsRequest,
+	).Scan(&msg.ID)
+	if errors.Is(err, sql.ErrNoRows) && msg.ClientMsgID != "" {
+		tx.Rollback()
+		return r.loadDuplicate(msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(msg.AttachmentIDs) > 0 {
+		rows, err := tx.Query(linkAttachments, msg.ID, pq.Array(msg.AttachmentIDs), msg.SenderID)
+		if err != nil {
+			return err
+		}
+		msg.Attachments, err = scanAttachments(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if len(msg.Attachments) != len(msg.AttachmentIDs) {
+			return ErrAttachmentUnavailable
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (r *InMemoryMessageRepo) Save(msg *dto.Message) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if msg.ID == 0 {
-		msg.ID = r.nextID
-		r.nextID++
+func (r *PostgresMessageRepo) loadDuplicate(msg *dto.Message) error {
+	existing, err := scanMessage(r.sqlDB.QueryRow(selectMessageByClientID, msg.SenderID, msg.ClientMsgID))
+	if err != nil {
+		return err
+	}
+	if err := r.attachAttachments([]*dto.Message{existing}); err != nil {
+		return err
+	}
+	*msg = *existing
+	return ErrDuplicateMessage
+}
+
+func (r *PostgresMessageRepo) Get(messageID int64) (*dto.Message, error) {
+	msg, err := scanMessage(r.sqlDB.QueryRow(selectMessage, messageID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	return msg, err
+}
+
+func (r *PostgresMessageRepo) UpdateText(messageID int64, text string, editedAt int64) (*dto.Message, error) {
+	tx, err := r.sqlDB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRow(selectMessageForUpdate, messageID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(insertMessageEdit, messageID, previous, editedAt); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(updateMessageText, messageID, text, editedAt); err != nil {
+		return nil, err
+	}
+
+	msg, err := scanMessage(tx.QueryRow(selectMessage, messageID))
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, tx.Commit()
+}
+
+func (r *PostgresMessageRepo) GetRevisions(messageID int64) ([]*dto.MessageRevision, error) {
+	rows, err := r.sqlDB.Query(selectMessageEdits, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*dto.MessageRevision, 0)
+	for rows.Next() {
+		rev := &dto.MessageRevision{}
+		if err := rows.Scan(&rev.Text, &rev.ReplacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *PostgresMessageRepo) GetConversation(user1ID, user2ID int64, query dto.HistoryQuery) ([]*dto.Message, error) {
+	page, err := r.queryPage(selectConversationAfter, selectConversationBefore, query, user1ID, user2ID)
+	if err != nil {
+		return nil, err
+	}
+	return page, r.decorate(page, user1ID)
+}
+
+func (r *PostgresMessageRepo) GetRoomHistory(roomID, viewerID int64, query dto.HistoryQuery) ([]*dto.Message, error) {
+	page, err := r.queryPage(selectRoomHistoryAfter, selectRoomHistoryBefore, query, roomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return page, r.decorate(page, viewerID)
+}
+
+func (r *PostgresMessageRepo) GetSince(userID, afterID int64, limit int) ([]*dto.Message, error) {
+	rows, err := r.sqlDB.Query(selectMessagesSince, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, r.decorate(msgs, userID)
+}
+
+func (r *PostgresMessageRepo) AddReaction(messageID, userID int64, emoji string, at int64) (bool, error) {
+	res, err := r.sqlDB.Exec(insertReaction, messageID, userID, emoji, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PostgresMessageRepo) RemoveReaction(messageID, userID int64, emoji string) (bool, error) {
+	res, err := r.sqlDB.Exec(deleteReaction, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// decorate loads what a history page shows next to each message.
+func (r *PostgresMessageRepo) decorate(msgs []*dto.Message, viewerID int64) error {
+	if err := r.attachReactions(msgs, viewerID); err != nil {
+		return err
+	}
+	return r.attachAttachments(msgs)
+}
+
+func (r *PostgresMessageRepo) attachAttachments(msgs []*dto.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*dto.Message, len(msgs))
+	ids := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		byID[msg.ID] = msg
+		ids = append(ids, msg.ID)
+	}
+
+	rows, err := r.sqlDB.Query(selectMessageAttachments, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	attachments, err := scanAttachments(rows)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		msg := byID[a.MessageID]
+		msg.Attachments = append(msg.Attachments, a)
 	}
-	r.messages = append(r.messages, msg)
 	return nil
 }
 
-func (r *InMemoryMessageRepo) GetConversation(user1ID, user2ID int64) ([]*dto.Message, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// attachReactions fills in the aggregated reactions of msgs as seen by
+// viewerID.
+func (r *PostgresMessageRepo) attachReactions(msgs []*dto.Message, viewerID int64) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*dto.Message, len(msgs))
+	ids := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		byID[msg.ID] = msg
+		ids = append(ids, msg.ID)
+	}
+
+	rows, err := r.sqlDB.Query(selectReactionCounts, pq.Array(ids), viewerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			messageID int64
+			reaction  = &dto.Reaction{}
+		)
+		if err := rows.Scan(&messageID, &reaction.Emoji, &reaction.Count, &reaction.Reacted); err != nil {
+			return err
+		}
+		msg := byID[messageID]
+		msg.Reactions = append(msg.Reactions, reaction)
+	}
+	return rows.Err()
+}
+
+func (r *PostgresMessageRepo) Hide(messageID, userID, at int64) error {
+	_, err := r.sqlDB.Exec(insertMessageHidden, messageID, userID, at)
+	return err
+}
 
-	var conv []*dto.Message
-	for _, m := range r.messages {
-		if (m.SenderID == user1ID && m.ReceiverID == user2ID) || 
-		   (m.SenderID == user2ID && m.ReceiverID == user1ID) {
-			conv = append(conv, m)
+func (r *PostgresMessageRepo) DeleteForEveryone(messageID, at int64) error {
+	tx, err := r.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(deleteMessageForEveryone, messageID, at)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrMessageNotFound
 		}
+		return err
+	}
+
+	if _, err := tx.Exec(deleteMessageEdits, messageID); err != nil {
+		return err
 	}
-	if conv == nil {
-		return nil, errors.New("no messages")
+
+	return tx.Commit()
+}
+
+// queryPage runs one of a pair of paged history queries. args are the
+// parameters preceding the cursors; the "after" query then takes AfterID,
+// BeforeID and Limit, the "before" query BeforeID and Limit.
+func (r *PostgresMessageRepo) queryPage(afterSQL, beforeSQL string, query dto.HistoryQuery, args ...interface{}) ([]*dto.Message, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	// Paging forward returns rows oldest first; paging backward (or loading
+	// the latest page) walks newest first and is reversed below.
+	if query.AfterID > 0 {
+		rows, err = r.sqlDB.Query(afterSQL, append(args, query.AfterID, query.BeforeID, query.Limit)...)
+	} else {
+		rows, err = r.sqlDB.Query(beforeSQL, append(args, query.BeforeID, query.Limit)...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.AfterID == 0 {
+		for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+			page[i], page[j] = page[j], page[i]
+		}
+	}
+
+	return page, nil
+}
+
+func (r *PostgresMessageRepo) Search(userID int64, query dto.SearchQuery) ([]*dto.SearchResult, error) {
+	rows, err := r.sqlDB.Query(searchMessages,
+		userID,
+		query.Text,
+		query.PartnerID,
+		query.From,
+		query.To,
+		query.BeforeID,
+		query.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*dto.SearchResult, 0)
+	for rows.Next() {
+		var (
+			result     = &dto.SearchResult{}
+			receiverID sql.NullInt64
+			roomID     sql.NullInt64
+		)
+		err := rows.Scan(&result.ID, &result.SenderID, &receiverID, &roomID, &result.CreatedAt, &result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		result.ReceiverID = receiverID.Int64
+		result.RoomID = roomID.Int64
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (r *PostgresMessageRepo) GetInbox(userID int64) ([]*dto.Conversation, error) {
+	rows, err := r.sqlDB.Query(selectInbox, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inbox := make([]*dto.Conversation, 0)
+	for rows.Next() {
+		conv := &dto.Conversation{}
+		msg, err := scanMessage(rows, &conv.User.ID, &conv.User.Username, &conv.UnreadCount)
+		if err != nil {
+			return nil, err
+		}
+		conv.LastMessage = msg
+		conv.LastMessageAt = msg.CreatedAt
+		inbox = append(inbox, conv)
+	}
+
+	return inbox, rows.Err()
+}
+
+func (r *PostgresMessageRepo) MarkDelivered(receiverID, messageID, at int64) (int64, bool, error) {
+	var senderID int64
+	err := r.sqlDB.QueryRow(markDelivered, receiverID, messageID, at).Scan(&senderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return senderID, true, nil
+}
+
+func (r *PostgresMessageRepo) MarkRead(readerID, peerID, upToID, at int64) ([]int64, error) {
+	rows, err := r.sqlDB.Query(markReadUpTo, readerID, peerID, upToID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+func (r *PostgresMessageRepo) GetPartnerIDs(userID int64) ([]int64, error) {
+	rows, err := r.sqlDB.Query(selectPartnerIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMessage reads the columns listed in messageColumns followed by any
+// extra columns the query selects after them.
+func scanMessage(row rowScanner, extra ...interface{}) (*dto.Message, error) {
+	var (
+		msg         = &dto.Message{}
+		receiverID  sql.NullInt64
+		roomID      sql.NullInt64
+		deliveredAt sql.NullInt64
+		readAt      sql.NullInt64
+		editedAt    sql.NullInt64
+		clientMsgID sql.NullString
+		replyToID   sql.NullInt64
+		replySender sql.NullInt64
+		replyText   sql.NullString
+		replyGone   bool
+	)
+	dest := []interface{}{
+		&msg.ID,
+		&msg.SenderID,
+		&receiverID,
+		&roomID,
+		&msg.Text,
+		&msg.CreatedAt,
+		&deliveredAt,
+		&readAt,
+		&editedAt,
+		&clientMsgID,
+		&msg.IsRequest,
+		&replyToID,
+		&replySender,
+		&replyText,
+		&replyGone,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	msg.ReceiverID = receiverID.Int64
+	msg.RoomID = roomID.Int64
+	msg.DeliveredAt = deliveredAt.Int64
+	msg.ReadAt = readAt.Int64
+	msg.EditedAt = editedAt.Int64
+	msg.ClientMsgID = clientMsgID.String
+	if replyToID.Valid {
+		msg.ReplyToID = replyToID.Int64
+		msg.ReplyTo = &dto.MessagePreview{
+			ID:       replyToID.Int64,
+			SenderID: replySender.Int64,
+			Text:     replyText.String,
+			Deleted:  replyGone,
+		}
+	}
+	return msg, nil
+}
+
+func scanMessages(rows *sql.Rows) ([]*dto.Message, error) {
+	msgs := make([]*dto.Message, 0)
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func scanIDs(rows *sql.Rows) ([]int64, error) {
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// nullableID maps the zero ID to SQL NULL for optional foreign keys.
+func nullableID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func scanAttachments(rows *sql.Rows) ([]*dto.Attachment, error) {
+	attachments := make([]*dto.Attachment, 0)
+	for rows.Next() {
+		var (
+			a         = &dto.Attachment{}
+			messageID sql.NullInt64
+		)
+		err := rows.Scan(&a.ID, &a.OwnerID, &messageID, &a.Name, &a.Size, &a.MimeType, &a.Checksum, &a.StorageKey, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		a.MessageID = messageID.Int64
+		attachments = append(attachments, a)
 	}
-	return conv, nil
+	return attachments, rows.Err()
 }
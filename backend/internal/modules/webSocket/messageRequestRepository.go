@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"database/sql"
+	"errors"
+	dto "lilyChat/internal/modules/dto"
+)
+
+var ErrMessageRequestNotFound = errors.New("message request not found")
+
+type MessageRequestRepository interface {
+	// GetStatus returns the status of the request from senderID to
+	// receiverID, or "" if there is none.
+	GetStatus(senderID, receiverID int64) (string, error)
+	// CreatePending opens a request unless one already exists, whatever its
+	// status, and reports whether it did.
+	CreatePending(senderID, receiverID, at int64) (bool, error)
+	List(receiverID int64) ([]*dto.MessageRequest, error)
+	// GetMessages returns the newest limit messages held in the request,
+	// oldest first.
+	GetMessages(senderID, receiverID int64, limit int) ([]*dto.Message, error)
+	// HasMessaged reports whether fromID ever sent toID a message outside of
+	// a request.
+	HasMessaged(fromID, toID int64) (bool, error)
+
+	// Accept moves the held messages into the receiver's conversation.
+	// Accept and Reject fail with ErrMessageRequestNotFound unless the
+	// request is pending.
+	Accept(senderID, receiverID, at int64) error
+	Reject(senderID, receiverID, at int64) error
+}
+
+type PostgresMessageRequestRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewPostgresMessageRequestRepo(sqlDB *sql.DB) *PostgresMessageRequestRepo {
+	return &PostgresMessageRequestRepo{
+		sqlDB: sqlDB,
+	}
+}
+
+func (r *PostgresMessageRequestRepo) GetStatus(senderID, receiverID int64) (string, error) {
+	var status string
+	err := r.sqlDB.QueryRow(selectMessageRequestStatus, senderID, receiverID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+func (r *PostgresMessageRequestRepo) CreatePending(senderID, receiverID, at int64) (bool, error) {
+	res, err := r.sqlDB.Exec(upsertMessageRequest, senderID, receiverID, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PostgresMessageRequestRepo) List(receiverID int64) ([]*dto.MessageRequest, error) {
+	rows, err := r.sqlDB.Query(selectMessageRequests, receiverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make([]*dto.MessageRequest, 0)
+	for rows.Next() {
+		req := &dto.MessageRequest{}
+		err := rows.Scan(
+			&req.User.ID,
+			&req.User.Username,
+			&req.CreatedAt,
+			&req.LastText,
+			&req.LastMessageAt,
+			&req.MessageCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+func (r *PostgresMessageRequestRepo) GetMessages(senderID, receiverID int64, limit int) ([]*dto.Message, error) {
+	rows, err := r.sqlDB.Query(selectRequestMessages, senderID, receiverID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+
+	msgRepo := &PostgresMessageRepo{sqlDB: r.sqlDB}
+	return msgs, msgRepo.decorate(msgs, receiverID)
+}
+
+func (r *PostgresMessageRequestRepo) HasMessaged(fromID, toID int64) (bool, error) {
+	var ok bool
+	err := r.sqlDB.QueryRow(selectHasMessaged, fromID, toID).Scan(&ok)
+	return ok, err
+}
+
+func (r *PostgresMessageRequestRepo) Accept(senderID, receiverID, at int64) error {
+	tx, err := r.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setMessageRequestStatus(tx, senderID, receiverID, dto.MessageRequestAccepted, at); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(releaseRequestMessages, senderID, receiverID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reject keeps the held messages where they are; they never reach the
+// receiver's conversations.
+func (r *PostgresMessageRequestRepo) Reject(senderID, receiverID, at int64) error {
+	return setMessageRequestStatus(r.sqlDB, senderID, receiverID, dto.MessageRequestRejected, at)
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func setMessageRequestStatus(db execer, senderID, receiverID int64, status string, at int64) error {
+	res, err := db.Exec(updateMessageRequestStatus, senderID, receiverID, status, at)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMessageRequestNotFound
+	}
+	return nil
+}
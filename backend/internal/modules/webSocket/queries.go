@@ -0,0 +1,381 @@
+package websocket
+
+// messageColumns is selected from messageFrom; rp is the message being
+// replied to, reduced to a short preview. A quoted message the viewer
+// deleted for themselves shows up like one deleted for everyone.
+const messageColumns = `m.id, m.sender_id, m.receiver_id, m.room_id, m.text, m.created_at, m.delivered_at, m.read_at, m.edited_at,
+       m.client_msg_id, m.is_request, m.reply_to_id, rp.sender_id,
+       CASE WHEN rh.message_id IS NULL THEN LEFT(rp.text, 100) END,
+       rp.deleted_at IS NOT NULL OR rh.message_id IS NOT NULL`
+
+// messageFrom ends in the user ID of the viewer, whom rh matches hidden
+// quoted messages against; each query appends the parameter holding it,
+// or 0 when the message is not read by anyone in particular.
+const messageFrom = `messages m
+LEFT JOIN messages rp ON rp.id = m.reply_to_id
+LEFT JOIN message_hidden rh ON rh.message_id = rp.id AND rh.user_id = `
+
+// insertMessage returns no row when the sender already has a message with
+// the same client_msg_id, which makes retried sends idempotent.
+const insertMessage = `
+INSERT INTO messages (sender_id, receiver_id, room_id, reply_to_id, text, created_at, client_msg_id, is_request)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (sender_id, client_msg_id) WHERE client_msg_id IS NOT NULL DO NOTHING
+RETURNING id;
+`
+
+const selectMessageByClientID = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$1
+WHERE m.sender_id = $1 AND m.client_msg_id = $2;
+`
+
+const selectMessage = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `0
+WHERE m.id = $1 AND m.deleted_at IS NULL;
+`
+
+// selectMessageForUpdate skips deleted messages, so an edit racing a
+// delete for everyone finds nothing to edit once the delete has committed.
+const selectMessageForUpdate = `
+SELECT text FROM messages
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE;
+`
+
+const insertMessageEdit = `
+INSERT INTO message_edits (message_id, text, edited_at)
+VALUES ($1, $2, $3);
+`
+
+const updateMessageText = `
+UPDATE messages
+SET text = $2, edited_at = $3
+WHERE id = $1;
+`
+
+const selectMessageEdits = `
+SELECT text, edited_at
+FROM message_edits
+WHERE message_id = $1
+ORDER BY id;
+`
+
+const insertMessageHidden = `
+INSERT INTO message_hidden (message_id, user_id, hidden_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (message_id, user_id) DO NOTHING;
+`
+
+// deleteMessageForEveryone keeps the row as a tombstone so replies and
+// receipts stay consistent, but drops its text and earlier revisions.
+const deleteMessageForEveryone = `
+UPDATE messages
+SET deleted_at = $2, text = ''
+WHERE id = $1 AND deleted_at IS NULL;
+`
+
+const deleteMessageEdits = `
+DELETE FROM message_edits
+WHERE message_id = $1;
+`
+
+const selectConversationBefore = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$1
+WHERE ((m.sender_id = $1 AND m.receiver_id = $2) OR (m.sender_id = $2 AND m.receiver_id = $1))
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+  AND NOT (m.is_request AND m.receiver_id = $1)
+  AND ($3::BIGINT = 0 OR m.id < $3::BIGINT)
+ORDER BY m.id DESC
+LIMIT $4;
+`
+
+const selectConversationAfter = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$1
+WHERE ((m.sender_id = $1 AND m.receiver_id = $2) OR (m.sender_id = $2 AND m.receiver_id = $1))
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+  AND NOT (m.is_request AND m.receiver_id = $1)
+  AND m.id > $3::BIGINT
+  AND ($4::BIGINT = 0 OR m.id < $4::BIGINT)
+ORDER BY m.id ASC
+LIMIT $5;
+`
+
+const selectRoomHistoryBefore = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$2
+WHERE m.room_id = $1
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $2)
+  AND ($3::BIGINT = 0 OR m.id < $3::BIGINT)
+ORDER BY m.id DESC
+LIMIT $4;
+`
+
+const selectRoomHistoryAfter = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$2
+WHERE m.room_id = $1
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $2)
+  AND m.id > $3::BIGINT
+  AND ($4::BIGINT = 0 OR m.id < $4::BIGINT)
+ORDER BY m.id ASC
+LIMIT $5;
+`
+
+// selectMessagesSince lists, oldest first, what $1 may have missed since
+// message $2: their direct messages in either direction (so other devices
+// catch up on what was sent) and messages in rooms they belong to.
+const selectMessagesSince = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$1
+WHERE m.id > $2
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+  AND NOT (m.is_request AND m.receiver_id = $1)
+  AND (
+      (m.room_id IS NULL AND (m.sender_id = $1 OR m.receiver_id = $1))
+      OR EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = $1)
+  )
+ORDER BY m.id ASC
+LIMIT $3;
+`
+
+// selectInbox picks the newest message per conversation partner and joins
+// the partner's public profile and the number of messages they sent that
+// $1 has not read yet.
+const selectInbox = `
+WITH last AS (
+    SELECT DISTINCT ON (peer_id)
+        CASE WHEN sender_id = $1 THEN receiver_id ELSE sender_id END AS peer_id,
+        id
+    FROM messages m
+    WHERE (sender_id = $1 OR receiver_id = $1) AND room_id IS NULL
+      AND deleted_at IS NULL
+      AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+      AND NOT (is_request AND receiver_id = $1)
+    ORDER BY peer_id, id DESC
+),
+unread AS (
+    SELECT sender_id AS peer_id, COUNT(*) AS unread_count
+    FROM messages m
+    WHERE receiver_id = $1 AND read_at IS NULL AND deleted_at IS NULL AND NOT is_request
+      AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+    GROUP BY sender_id
+)
+SELECT ` + messageColumns + `, u.id, u.username, COALESCE(un.unread_count, 0)
+FROM last
+JOIN messages m ON m.id = last.id
+LEFT JOIN messages rp ON rp.id = m.reply_to_id
+LEFT JOIN message_hidden rh ON rh.message_id = rp.id AND rh.user_id = $1
+JOIN users u ON u.id = last.peer_id
+LEFT JOIN unread un ON un.peer_id = last.peer_id
+ORDER BY m.id DESC;
+`
+
+const markDelivered = `
+UPDATE messages
+SET delivered_at = $3
+WHERE id = $2 AND receiver_id = $1 AND delivered_at IS NULL
+RETURNING sender_id;
+`
+
+// markReadUpTo also stamps delivered_at so a read receipt never leaves a
+// message looking undelivered.
+const markReadUpTo = `
+UPDATE messages
+SET read_at = $4, delivered_at = COALESCE(delivered_at, $4)
+WHERE receiver_id = $1 AND sender_id = $2 AND id <= $3 AND read_at IS NULL
+RETURNING id;
+`
+
+// selectPartnerIDs leaves out pending message requests, so neither side
+// of one sees the other's presence.
+const selectPartnerIDs = `
+SELECT DISTINCT CASE WHEN sender_id = $1 THEN receiver_id ELSE sender_id END
+FROM messages
+WHERE (sender_id = $1 OR receiver_id = $1) AND room_id IS NULL AND sender_id <> receiver_id
+  AND NOT is_request;
+`
+
+const insertRoom = `
+INSERT INTO rooms (name, owner_id, created_at)
+VALUES ($1, $2, $3)
+RETURNING id;
+`
+
+const selectRoom = `
+SELECT id, name, owner_id, created_at
+FROM rooms
+WHERE id = $1;
+`
+
+const selectRoomsForUser = `
+SELECT r.id, r.name, r.owner_id, r.created_at
+FROM rooms r
+JOIN room_members rm ON rm.room_id = r.id
+WHERE rm.user_id = $1
+ORDER BY r.id DESC;
+`
+
+const insertRoomMember = `
+INSERT INTO room_members (room_id, user_id, joined_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (room_id, user_id) DO NOTHING;
+`
+
+const deleteRoomMember = `
+DELETE FROM room_members
+WHERE room_id = $1 AND user_id = $2;
+`
+
+const selectRoomMembers = `
+SELECT u.id, u.username
+FROM room_members rm
+JOIN users u ON u.id = rm.user_id
+WHERE rm.room_id = $1
+ORDER BY rm.joined_at, u.id;
+`
+
+const selectIsRoomMember = `
+SELECT EXISTS (
+    SELECT 1 FROM room_members
+    WHERE room_id = $1 AND user_id = $2
+);
+`
+
+const insertReaction = `
+INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (message_id, user_id, emoji) DO NOTHING;
+`
+
+const deleteReaction = `
+DELETE FROM message_reactions
+WHERE message_id = $1 AND user_id = $2 AND emoji = $3;
+`
+
+// selectReactionCounts aggregates reactions per message and emoji, in the
+// order each emoji was first used, flagging the ones $2 added.
+const selectReactionCounts = `
+SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id = $2)
+FROM message_reactions
+WHERE message_id = ANY($1)
+GROUP BY message_id, emoji
+ORDER BY message_id, MIN(created_at);
+`
+
+const attachmentColumns = `id, owner_id, message_id, name, size, mime_type, checksum, storage_key, created_at`
+
+// linkAttachments claims $2 for message $1; only attachments uploaded by
+// the sender and not yet used by another message qualify.
+const linkAttachments = `
+UPDATE attachments
+SET message_id = $1
+WHERE id = ANY($2) AND owner_id = $3 AND message_id IS NULL
+RETURNING ` + attachmentColumns + `;
+`
+
+const selectMessageAttachments = `
+SELECT ` + attachmentColumns + `
+FROM attachments
+WHERE message_id = ANY($1)
+ORDER BY id;
+`
+
+// searchMessages matches $2 against every direct conversation $1 takes part
+// in and every room $1 is a member of, newest first. The text is escaped
+// before ts_headline so the only markup in a snippet is our <mark> tags.
+const searchMessages = `
+SELECT m.id, m.sender_id, m.receiver_id, m.room_id, m.created_at,
+       ts_headline('simple',
+                   replace(replace(replace(m.text, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+                   q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+FROM messages m, websearch_to_tsquery('simple', $2) q
+WHERE m.search_vector @@ q
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+  AND NOT (m.is_request AND m.receiver_id = $1)
+  AND (
+      (m.room_id IS NULL AND (m.sender_id = $1 OR m.receiver_id = $1))
+      OR EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = $1)
+  )
+  AND ($3::BIGINT = 0 OR (m.room_id IS NULL
+       AND ((m.sender_id = $1 AND m.receiver_id = $3::BIGINT) OR (m.sender_id = $3::BIGINT AND m.receiver_id = $1))))
+  AND ($4::BIGINT = 0 OR m.created_at >= $4::BIGINT)
+  AND ($5::BIGINT = 0 OR m.created_at < $5::BIGINT)
+  AND ($6::BIGINT = 0 OR m.id < $6::BIGINT)
+ORDER BY m.id DESC
+LIMIT $7;
+`
+
+const upsertMessageRequest = `
+INSERT INTO message_requests (sender_id, receiver_id, status, created_at)
+VALUES ($1, $2, 'pending', $3)
+ON CONFLICT (sender_id, receiver_id) DO NOTHING;
+`
+
+const selectMessageRequestStatus = `
+SELECT status FROM message_requests
+WHERE sender_id = $1 AND receiver_id = $2;
+`
+
+// selectMessageRequests lists the pending requests addressed to $1 with the
+// latest message and the number of messages waiting in each.
+const selectMessageRequests = `
+SELECT u.id, u.username, r.created_at, last.text, last.created_at, waiting.count
+FROM message_requests r
+JOIN users u ON u.id = r.sender_id
+JOIN LATERAL (
+    SELECT m.text, m.created_at
+    FROM messages m
+    WHERE m.sender_id = r.sender_id AND m.receiver_id = r.receiver_id
+      AND m.is_request AND m.deleted_at IS NULL
+    ORDER BY m.id DESC
+    LIMIT 1
+) last ON TRUE
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS count
+    FROM messages m
+    WHERE m.sender_id = r.sender_id AND m.receiver_id = r.receiver_id
+      AND m.is_request AND m.deleted_at IS NULL
+) waiting
+WHERE r.receiver_id = $1 AND r.status = 'pending'
+ORDER BY last.created_at DESC;
+`
+
+// selectRequestMessages returns the newest $3 messages waiting from $1 to
+// $2, newest first.
+const selectRequestMessages = `
+SELECT ` + messageColumns + `
+FROM ` + messageFrom + `$2
+WHERE m.sender_id = $1 AND m.receiver_id = $2
+  AND m.is_request AND m.deleted_at IS NULL
+ORDER BY m.id DESC
+LIMIT $3;
+`
+
+const updateMessageRequestStatus = `
+UPDATE message_requests
+SET status = $3, responded_at = $4
+WHERE sender_id = $1 AND receiver_id = $2 AND status = 'pending';
+`
+
+const releaseRequestMessages = `
+UPDATE messages
+SET is_request = FALSE
+WHERE sender_id = $1 AND receiver_id = $2 AND is_request;
+`
+
+const selectHasMessaged = `
+SELECT EXISTS (
+    SELECT 1 FROM messages
+    WHERE sender_id = $1 AND receiver_id = $2 AND NOT is_request
+);
+`
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idleSweepInterval is how often buckets of users who went quiet are
+// dropped, so the map does not grow with every user ever seen.
+const idleSweepInterval = time.Minute
+
+// Decision is the outcome of Limiter.Allow.
+type Decision int
+
+const (
+	Allowed Decision = iota
+	// Rejected means the frame must be dropped.
+	Rejected
+	// Disconnect means the frame must be dropped and the user has exceeded
+	// the limit often enough to be kicked.
+	Disconnect
+)
+
+// Stats are cumulative counters, for tuning the limits.
+type Stats struct {
+	Allowed     uint64 `json:"allowed"`
+	Rejected    uint64 `json:"rejected"`
+	Disconnects uint64 `json:"disconnects"`
+	Tracked     int    `json:"tracked_users"`
+}
+
+// Limiter is a token bucket per user, shared by all of the user's
+// connections. Each frame takes a token; tokens refill at rate per second
+// up to burst. A user rejected maxViolations times within window is told
+// to disconnect.
+type Limiter struct {
+	rate          float64
+	burst         float64
+	maxViolations int
+	window        time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[int64]*bucket
+	lastSweep time.Time
+
+	allowed     atomic.Uint64
+	rejected    atomic.Uint64
+	disconnects atomic.Uint64
+}
+
+type bucket struct {
+	tokens      float64
+	updated     time.Time
+	violations  int
+	windowStart time.Time
+}
+
+func NewLimiter(rate float64, burst, maxViolations int, window time.Duration) *Limiter {
+	return &Limiter{
+		rate:          rate,
+		burst:         float64(burst),
+		maxViolations: maxViolations,
+		window:        window,
+		now:           time.Now,
+		buckets:       make(map[int64]*bucket),
+		lastSweep:     time.Now(),
+	}
+}
+
+func (l *Limiter) Allow(userID int64) Decision {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[userID] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		l.allowed.Add(1)
+		return Allowed
+	}
+
+	l.rejected.Add(1)
+	if now.Sub(b.windowStart) > l.window {
+		b.windowStart = now
+		b.violations = 0
+	}
+	b.violations++
+	if b.violations >= l.maxViolations {
+		b.violations = 0
+		l.disconnects.Add(1)
+		return Disconnect
+	}
+	return Rejected
+}
+
+// sweep drops buckets that have refilled completely; a new bucket for the
+// same user would start out identical.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for userID, b := range l.buckets {
+		refilled := b.tokens + now.Sub(b.updated).Seconds()*l.rate
+		if refilled >= l.burst && now.Sub(b.windowStart) > l.window {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	tracked := len(l.buckets)
+	l.mu.Unlock()
+
+	return Stats{
+		Allowed:     l.allowed.Load(),
+		Rejected:    l.rejected.Load(),
+		Disconnects: l.disconnects.Load(),
+		Tracked:     tracked,
+	}
+}
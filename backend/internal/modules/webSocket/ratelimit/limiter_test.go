@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// step is one Allow call, made after advancing the clock by wait.
+type step struct {
+	wait   time.Duration
+	userID int64
+	want   Decision
+}
+
+func TestLimiterAllow(t *testing.T) {
+	const (
+		rate          = 2 // tokens per second
+		burst         = 3
+		maxViolations = 3
+		window        = 10 * time.Second
+	)
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"burst is allowed", []step{
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+		}},
+		{"frames past the burst are rejected", []step{
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+		}},
+		{"tokens refill at rate", []step{
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+			{500 * time.Millisecond, 1, Allowed},
+			{0, 1, Rejected},
+		}},
+		{"refill stops at burst", []step{
+			{0, 1, Allowed},
+			{time.Hour, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+		}},
+		{"users have separate buckets", []step{
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+			{0, 2, Allowed},
+		}},
+		{"repeated violations disconnect", []step{
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+			{0, 1, Rejected},
+			{0, 1, Disconnect},
+			// The count starts over after a disconnect.
+			{0, 1, Rejected},
+		}},
+		{"violations outside the window are forgotten", []step{
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+			{0, 1, Rejected},
+			{window + time.Second, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Allowed},
+			{0, 1, Rejected},
+			{0, 1, Rejected},
+			{0, 1, Disconnect},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1_700_000_000, 0)
+			l := NewLimiter(rate, burst, maxViolations, window)
+			l.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.wait)
+				if got := l.Allow(s.userID); got != s.want {
+					t.Fatalf("step %d: Allow(%d) = %v, want %v", i, s.userID, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterStats(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLimiter(1, 1, 2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow(1) // allowed
+	l.Allow(1) // rejected
+	l.Allow(1) // rejected, disconnect
+	l.Allow(2) // allowed
+
+	want := Stats{Allowed: 2, Rejected: 2, Disconnects: 1, Tracked: 2}
+	if got := l.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLimiterSweepsIdleUsers(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLimiter(1, 1, 5, time.Second)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	l.Allow(1)
+	now = now.Add(idleSweepInterval)
+	l.Allow(2)
+
+	if got := l.Stats().Tracked; got != 1 {
+		t.Errorf("tracked users = %d, want 1 after the idle one was swept", got)
+	}
+}
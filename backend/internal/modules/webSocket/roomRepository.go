@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"database/sql"
+	"errors"
+	dto "lilyChat/internal/modules/dto"
+)
+
+var ErrRoomNotFound = errors.New("room not found")
+
+type RoomRepository interface {
+	// Create stores room and makes its owner and memberIDs members of it.
+	Create(room *dto.Room, memberIDs []int64) error
+	Get(roomID int64) (*dto.Room, error)
+	ListForUser(userID int64) ([]*dto.Room, error)
+
+	AddMember(roomID, userID, joinedAt int64) error
+	RemoveMember(roomID, userID int64) error
+	GetMembers(roomID int64) ([]*dto.PublicUser, error)
+	IsMember(roomID, userID int64) (bool, error)
+}
+
+type PostgresRoomRepo struct {
+	sqlDB *sql.DB
+}
+
+func NewPostgresRoomRepo(sqlDB *sql.DB) *PostgresRoomRepo {
+	return &PostgresRoomRepo{
+		sqlDB: sqlDB,
+	}
+}
+
+func (r *PostgresRoomRepo) Create(room *dto.Room, memberIDs []int64) error {
+	tx, err := r.sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(insertRoom, room.Name, room.OwnerID, room.CreatedAt).Scan(&room.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range append([]int64{room.OwnerID}, memberIDs...) {
+		if _, err := tx.Exec(insertRoomMember, room.ID, userID, room.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRoomRepo) Get(roomID int64) (*dto.Room, error) {
+	room := &dto.Room{}
+	err := r.sqlDB.QueryRow(selectRoom, roomID).Scan(&room.ID, &room.Name, &room.OwnerID, &room.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func (r *PostgresRoomRepo) ListForUser(userID int64) ([]*dto.Room, error) {
+	rows, err := r.sqlDB.Query(selectRoomsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := make([]*dto.Room, 0)
+	for rows.Next() {
+		room := &dto.Room{}
+		if err := rows.Scan(&room.ID, &room.Name, &room.OwnerID, &room.CreatedAt); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+func (r *PostgresRoomRepo) AddMember(roomID, userID, joinedAt int64) error {
+	_, err := r.sqlDB.Exec(insertRoomMember, roomID, userID, joinedAt)
+	return err
+}
+
+func (r *PostgresRoomRepo) RemoveMember(roomID, userID int64) error {
+	_, err := r.sqlDB.Exec(deleteRoomMember, roomID, userID)
+	return err
+}
+
+func (r *PostgresRoomRepo) GetMembers(roomID int64) ([]*dto.PublicUser, error) {
+	rows, err := r.sqlDB.Query(selectRoomMembers, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]*dto.PublicUser, 0)
+	for rows.Next() {
+		member := &dto.PublicUser{}
+		if err := rows.Scan(&member.ID, &member.Username); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (r *PostgresRoomRepo) IsMember(roomID, userID int64) (bool, error) {
+	var isMember bool
+	err := r.sqlDB.QueryRow(selectIsRoomMember, roomID, userID).Scan(&isMember)
+	return isMember, err
+}
@@ -1,26 +1,136 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/config"
+	blocksRepo "lilyChat/internal/modules/blocks/repository"
+	contactsRepo "lilyChat/internal/modules/contacts/repository"
 	dto "lilyChat/internal/modules/dto"
+	notifications "lilyChat/internal/modules/notifications/service"
+	usersRepo "lilyChat/internal/modules/users/repository"
 	websocket "lilyChat/internal/modules/webSocket"
 	"lilyChat/internal/modules/webSocket/hub"
+	"log"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+)
+
+const (
+	DeleteForMe       = "me"
+	DeleteForEveryone = "everyone"
+)
+
+const (
+	ReactionAdd    = "add"
+	ReactionRemove = "remove"
+)
+
+const (
+	MessageRequestReceived = "received"
+	MessageRequestAccepted = "accepted"
+	MessageRequestRejected = "rejected"
+)
+
+const maxAttachmentsPerMessage = 10
+
+const maxClientMsgIDLength = 64
+
+// replyPreviewRunes matches the LEFT(rp.text, 100) used for stored previews.
+const replyPreviewRunes = 100
+
+// maxEmojiRunes leaves room for skin tones and ZWJ sequences such as family
+// emoji while keeping reactions from turning into free text.
+const maxEmojiRunes = 16
+
+const (
+	emojiZWJ    = '\u200d'
+	emojiKeycap = '\u20e3'
+)
+
+var (
+	ErrNotMessageSender = errors.New("only the sender can change this message")
+	ErrNotParticipant   = errors.New("not a participant of this conversation")
+	ErrBlocked          = errors.New("you cannot message this user")
+	// ErrNotAccepting is returned when the receiver's privacy setting
+	// refuses messages from the sender.
+	ErrNotAccepting = errors.New("this user does not accept messages from you")
+	// ErrClientMsgIDConflict is returned when a client_msg_id is reused for
+	// a message to a different conversation than the one it was first used
+	// for.
+	ErrClientMsgIDConflict = errors.New("client_msg_id already used for another conversation")
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 100
+)
+
+const (
+	syncPageSize = 100
+	// maxSyncMessages bounds how much a reconnect replays; a client that was
+	// away longer is told to reload from the history API instead.
+	maxSyncMessages = 1000
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchRunes     = 200
 )
 
 type ChatServicer interface {
-	SendMessage(senderID, receiverID int64, text string) error
+	Connect(client *hub.Client, lastSeenID int64)
+	Disconnect(client *hub.Client)
+	SendMessage(senderID int64, req dto.SendMessageRequest) (*dto.Message, error)
+	GetConversation(userID, peerID int64, query dto.HistoryQuery) ([]*dto.Message, error)
+	GetInbox(userID int64) ([]*dto.Conversation, error)
+	Search(userID int64, query dto.SearchQuery) ([]*dto.SearchResult, error)
+	ListMessageRequests(userID int64) ([]*dto.MessageRequest, error)
+	GetRequestMessages(userID, senderID int64, limit int) ([]*dto.Message, error)
+	AcceptMessageRequest(userID, senderID int64) error
+	RejectMessageRequest(userID, senderID int64) error
+	AckDelivered(userID, messageID int64) error
+	MarkRead(userID, peerID, upToID int64) error
+	SetTyping(senderID, receiverID int64, state string) error
+	EditMessage(userID, messageID int64, text string) (*dto.Message, error)
+	GetRevisions(userID, messageID int64) ([]*dto.MessageRevision, error)
+	DeleteMessage(userID, messageID int64, scope string) error
+	React(userID, messageID int64, emoji, action string) error
 	GetHub() *hub.Hub
 }
 
 type ChatService struct {
-	msgRepo websocket.MessageRepository
-	hub     *hub.Hub
+	msgRepo       websocket.MessageRepository
+	roomRepo      websocket.RoomRepository
+	requestRepo   websocket.MessageRequestRepository
+	usersRepo     usersRepo.UsersRepositorier
+	blocksRepo    blocksRepo.BlocksRepositorier
+	contactsRepo  contactsRepo.ContactsRepositorier
+	notifications notifications.NotificationsServicer
+	hub           *hub.Hub
+	cfg           config.ChatConfig
 }
 
-func NewChatService(msgRepo websocket.MessageRepository, hub *hub.Hub) *ChatService {
+func NewChatService(msgRepo websocket.MessageRepository, roomRepo websocket.RoomRepository, requestRepo websocket.MessageRequestRepository, usersRepo usersRepo.UsersRepositorier, blocksRepo blocksRepo.BlocksRepositorier, contactsRepo contactsRepo.ContactsRepositorier, notifications notifications.NotificationsServicer, components *components.Components) *ChatService {
 	return &ChatService{
-		msgRepo: msgRepo,
-		hub:     hub,
+		msgRepo:       msgRepo,
+		roomRepo:      roomRepo,
+		requestRepo:   requestRepo,
+		usersRepo:     usersRepo,
+		blocksRepo:    blocksRepo,
+		contactsRepo:  contactsRepo,
+		notifications: notifications,
+		hub:           components.WSHub,
+		cfg:           components.Conf.Chat,
 	}
 }
 
@@ -28,18 +138,700 @@ func (s *ChatService) GetHub() *hub.Hub {
 	return s.hub
 }
 
-func (s *ChatService) SendMessage(senderID, receiverID int64, text string) error {
+// Connect registers client with the hub. When it is the user's first
+// connection, the user is recorded as seen and their conversation partners
+// are told they are online.
+//
+// A non-zero lastSeenID is the newest message the client already has;
+// everything after it is replayed to conn before live delivery takes over.
+// The replay runs once before registering and once more after, so a message
+// saved in between is never lost. Live events arriving during the replay
+// are held back and sent after it. A message may reach the client twice
+// around that switch, so clients should de-duplicate by ID.
+func (s *ChatService) Connect(client *hub.Client, lastSeenID int64) {
+	userID := client.UserID
+	var (
+		msgs     []*dto.Message
+		complete = true
+	)
+	if lastSeenID > 0 {
+		client.BeginReplay()
+		msgs, lastSeenID, complete = s.missedMessages(userID, lastSeenID, maxSyncMessages)
+	}
+
+	if s.hub.Register(client) {
+		s.broadcastPresence(userID, true)
+	}
+
+	if lastSeenID > 0 {
+		if complete {
+			var more []*dto.Message
+			more, lastSeenID, complete = s.missedMessages(userID, lastSeenID, maxSyncMessages-len(msgs))
+			msgs = append(msgs, more...)
+		}
+		s.hub.ReplayMessages(client, msgs, lastSeenID, complete)
+	}
+}
+
+// missedMessages pages through messages after afterID until it runs out or
+// has collected max of them. It returns them with the last ID read and
+// whether nothing was left behind.
+func (s *ChatService) missedMessages(userID, afterID int64, max int) ([]*dto.Message, int64, bool) {
+	msgs := []*dto.Message{}
+	for len(msgs) < max {
+		page, err := s.msgRepo.GetSince(userID, afterID, min(syncPageSize, max-len(msgs)))
+		if err != nil {
+			return msgs, afterID, false
+		}
+		if len(page) == 0 {
+			return msgs, afterID, true
+		}
+		msgs = append(msgs, page...)
+		afterID = page[len(page)-1].ID
+	}
+	return msgs, afterID, false
+}
+
+// Disconnect is the counterpart of Connect for a closed connection; the user
+// only goes offline once their last connection is gone.
+func (s *ChatService) Disconnect(client *hub.Client) {
+	if s.hub.Unregister(client) {
+		s.broadcastPresence(client.UserID, false)
+	}
+}
+
+func (s *ChatService) broadcastPresence(userID int64, online bool) {
+	now := time.Now().Unix()
+	if err := s.usersRepo.UpdateLastSeen(context.Background(), userID, now); err != nil {
+		log.Printf("[Chat] cannot update last seen of user %d: %v", userID, err)
+	}
+
+	partnerIDs, err := s.msgRepo.GetPartnerIDs(userID)
+	if err != nil {
+		return
+	}
+	blockedIDs, err := s.blocksRepo.GetBlockedPeers(userID)
+	if err != nil {
+		return
+	}
+	s.hub.SendPresence(without(partnerIDs, blockedIDs), userID, online, now)
+}
+
+// without returns ids minus every ID in exclude.
+func without(ids, exclude []int64) []int64 {
+	if len(exclude) == 0 {
+		return ids
+	}
+
+	skip := make(map[int64]bool, len(exclude))
+	for _, id := range exclude {
+		skip[id] = true
+	}
+	kept := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !skip[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// SendMessage stores a direct message and delivers it, unless the
+// receiver's privacy setting holds it in a message request: then only the
+// sender gets it, and the receiver is told about the request while it is
+// pending.
+func (s *ChatService) SendMessage(senderID int64, req dto.SendMessageRequest) (*dto.Message, error) {
+	if err := s.checkReceiver(senderID, req.ReceiverID); err != nil {
+		return nil, err
+	}
+
+	requestStatus, err := s.requestStatus(senderID, req.ReceiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := messageText(req.Text, s.cfg.MaxMessageLength, len(req.AttachmentIDs) > 0)
+	if err != nil {
+		return nil, err
+	}
+
 	msg := &dto.Message{
 		SenderID:   senderID,
-		ReceiverID: receiverID,
+		ReceiverID: req.ReceiverID,
 		Text:       text,
 		CreatedAt:  time.Now().Unix(),
+		IsRequest:  requestStatus != "",
+	}
+	if err := attachReply(s.msgRepo, msg, req.ReplyTo); err != nil {
+		return nil, err
+	}
+	if err := attachFiles(msg, req.AttachmentIDs); err != nil {
+		return nil, err
+	}
+	if err := setClientMsgID(msg, req.ClientMsgID); err != nil {
+		return nil, err
+	}
+	err = s.msgRepo.Save(msg)
+	if errors.Is(err, websocket.ErrDuplicateMessage) {
+		if msg.RoomID != 0 || msg.ReceiverID != req.ReceiverID {
+			return nil, ErrClientMsgIDConflict
+		}
+		s.hub.ResendMessage(senderID, msg)
+		return msg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !msg.IsRequest {
+		s.hub.SendMessage(msg)
+		s.notifications.Notify([]int64{msg.ReceiverID}, messageNotification(dto.NotificationMessage, msg))
+		return msg, nil
+	}
+
+	if requestStatus == dto.MessageRequestPending {
+		if _, err := s.requestRepo.CreatePending(senderID, msg.ReceiverID, msg.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	s.hub.SendHeldMessage(msg)
+	// A rejected request keeps collecting messages without telling the
+	// receiver, so the sender cannot tell it was rejected.
+	if requestStatus == dto.MessageRequestPending {
+		s.hub.SendMessageRequest([]int64{msg.ReceiverID}, senderID, MessageRequestReceived, msg)
+		s.notifications.Notify([]int64{msg.ReceiverID}, messageNotification(dto.NotificationMessageRequest, msg))
+	}
+	return msg, nil
+}
+
+// requestStatus applies the receiver's privacy setting to a message from
+// senderID. It returns "" when the message goes straight into the
+// conversation, or else the status of the message request that holds it,
+// pending for a new one. Once the receiver has written to the sender
+// themselves, the conversation is always open.
+func (s *ChatService) requestStatus(senderID, receiverID int64) (string, error) {
+	privacy, err := s.usersRepo.GetPrivacy(context.Background(), receiverID)
+	if err != nil {
+		return "", err
+	}
+	if privacy == dto.PrivacyEveryone {
+		return "", nil
+	}
+
+	replied, err := s.requestRepo.HasMessaged(receiverID, senderID)
+	if err != nil {
+		return "", err
+	}
+	if replied {
+		return "", nil
+	}
+	if privacy == dto.PrivacyNobody {
+		return "", ErrNotAccepting
+	}
+
+	contacts, err := s.contactsRepo.AreContacts(senderID, receiverID)
+	if err != nil {
+		return "", err
+	}
+	if contacts {
+		return "", nil
+	}
+
+	status, err := s.requestRepo.GetStatus(senderID, receiverID)
+	if err != nil {
+		return "", err
+	}
+	switch status {
+	case "":
+		return dto.MessageRequestPending, nil
+	case dto.MessageRequestAccepted:
+		return "", nil
+	default:
+		return status, nil
+	}
+}
+
+func (s *ChatService) checkReceiver(senderID, receiverID int64) error {
+	if receiverID <= 0 {
+		return ErrReceiverNotFound
+	}
+	if receiverID == senderID {
+		return ErrSelfMessage
+	}
+
+	exists, err := s.usersRepo.Exists(context.Background(), receiverID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrReceiverNotFound
+	}
+
+	blocked, err := s.blocksRepo.IsBlockedEither(senderID, receiverID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+func (s *ChatService) GetConversation(userID, peerID int64, query dto.HistoryQuery) ([]*dto.Message, error) {
+	return s.msgRepo.GetConversation(userID, peerID, clampHistoryQuery(query))
+}
+
+func clampHistoryQuery(query dto.HistoryQuery) dto.HistoryQuery {
+	if query.Limit <= 0 {
+		query.Limit = defaultHistoryLimit
+	}
+	if query.Limit > maxHistoryLimit {
+		query.Limit = maxHistoryLimit
+	}
+	return query
+}
+
+func (s *ChatService) GetInbox(userID int64) ([]*dto.Conversation, error) {
+	return s.msgRepo.GetInbox(userID)
+}
+
+// Search looks for query.Text in the caller's direct conversations and
+// rooms. The text uses web search syntax: quoted phrases, "or" and -word.
+func (s *ChatService) Search(userID int64, query dto.SearchQuery) ([]*dto.SearchResult, error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, errors.New("search text is required")
+	}
+	if utf8.RuneCountInString(query.Text) > maxSearchRunes {
+		return nil, errors.New("search text too long")
+	}
+	if query.From > 0 && query.To > 0 && query.From >= query.To {
+		return nil, errors.New("from must be before to")
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit > maxSearchLimit {
+		query.Limit = maxSearchLimit
+	}
+
+	return s.msgRepo.Search(userID, query)
+}
+
+// ListMessageRequests lists the pending message requests addressed to
+// userID, most recently active first.
+func (s *ChatService) ListMessageRequests(userID int64) ([]*dto.MessageRequest, error) {
+	return s.requestRepo.List(userID)
+}
+
+// GetRequestMessages lets userID read what senderID wrote in a message
+// request before deciding on it. Reading does not mark anything read.
+func (s *ChatService) GetRequestMessages(userID, senderID int64, limit int) ([]*dto.Message, error) {
+	query := clampHistoryQuery(dto.HistoryQuery{Limit: limit})
+	return s.requestRepo.GetMessages(senderID, userID, query.Limit)
+}
+
+// AcceptMessageRequest moves the messages senderID wrote into userID's
+// conversations and lets further messages through.
+func (s *ChatService) AcceptMessageRequest(userID, senderID int64) error {
+	if err := s.requestRepo.Accept(senderID, userID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	s.hub.SendMessageRequest([]int64{userID}, senderID, MessageRequestAccepted, nil)
+	return nil
+}
+
+// RejectMessageRequest drops the request from userID's list. The sender is
+// not told; whatever they send afterwards stays hidden as well.
+func (s *ChatService) RejectMessageRequest(userID, senderID int64) error {
+	if err := s.requestRepo.Reject(senderID, userID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	s.hub.SendMessageRequest([]int64{userID}, senderID, MessageRequestRejected, nil)
+	return nil
+}
+
+func (s *ChatService) AckDelivered(userID, messageID int64) error {
+	if messageID <= 0 {
+		return errors.New("message_id is required")
+	}
+
+	now := time.Now().Unix()
+	senderID, ok, err := s.msgRepo.MarkDelivered(userID, messageID, now)
+	if err != nil || !ok {
+		return err
+	}
+
+	if blocked, err := s.blocksRepo.IsBlockedEither(userID, senderID); err != nil || blocked {
+		return err
+	}
+	s.hub.SendReceipt(senderID, userID, ReceiptDelivered, []int64{messageID}, now)
+	return nil
+}
+
+func (s *ChatService) MarkRead(userID, peerID, upToID int64) error {
+	if peerID <= 0 || upToID <= 0 {
+		return errors.New("peer_id and message_id are required")
+	}
+
+	now := time.Now().Unix()
+	ids, err := s.msgRepo.MarkRead(userID, peerID, upToID, now)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	// Receipts are not sent across a block; the messages still count as
+	// read for the reader.
+	if blocked, err := s.blocksRepo.IsBlockedEither(userID, peerID); err != nil || blocked {
+		return err
+	}
+	s.hub.SendReceipt(peerID, userID, ReceiptRead, ids, now)
+	return nil
+}
+
+func (s *ChatService) SetTyping(senderID, receiverID int64, state string) error {
+	if receiverID <= 0 || receiverID == senderID {
+		return errors.New("invalid receiver_id")
+	}
+
+	// Typing between blocked users is dropped silently rather than
+	// rejected, so it does not reveal the block.
+	blocked, err := s.blocksRepo.IsBlockedEither(senderID, receiverID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return nil
+	}
+
+	switch state {
+	case hub.TypingStart:
+		s.hub.StartTyping(senderID, receiverID)
+	case hub.TypingStop:
+		s.hub.StopTyping(senderID, receiverID)
+	default:
+		return errors.New("state must be start or stop")
+	}
+	return nil
+}
+
+// EditMessage replaces the text of one of userID's own messages and pushes
+// the new version to everyone who received it.
+func (s *ChatService) EditMessage(userID, messageID int64, text string) (*dto.Message, error) {
+	text, err := normalizeText(text, s.cfg.MaxMessageLength)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	msg, err := s.msgRepo.Get(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+
+	updated, err := s.msgRepo.UpdateText(messageID, text, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	audienceIDs, err := s.audience(updated)
+	if err != nil {
+		return nil, err
+	}
+	s.hub.SendMessageUpdated(audienceIDs, updated)
+
+	return updated, nil
+}
+
+func (s *ChatService) GetRevisions(userID, messageID int64) ([]*dto.MessageRevision, error) {
+	if _, err := s.accessibleMessage(userID, messageID); err != nil {
+		return nil, err
+	}
+
+	return s.msgRepo.GetRevisions(messageID)
+}
+
+// DeleteMessage hides a message from userID's history (DeleteForMe) or, for
+// its sender and within the configured window, removes it for every
+// participant (DeleteForEveryone).
+func (s *ChatService) DeleteMessage(userID, messageID int64, scope string) error {
+	msg, err := s.accessibleMessage(userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch scope {
+	case "", DeleteForMe:
+		if err := s.msgRepo.Hide(messageID, userID, now.Unix()); err != nil {
+			return err
+		}
+		s.hub.SendMessageDeleted([]int64{userID}, msg, DeleteForMe)
+		return nil
+
+	case DeleteForEveryone:
+		if msg.SenderID != userID {
+			return ErrNotMessageSender
+		}
+		if now.After(time.Unix(msg.CreatedAt, 0).Add(s.cfg.DeleteWindow)) {
+			return errors.New("message is too old to delete for everyone")
+		}
+		if err := s.msgRepo.DeleteForEveryone(messageID, now.Unix()); err != nil {
+			return err
+		}
+
+		audienceIDs, err := s.audience(msg)
+		if err != nil {
+			return err
+		}
+		s.hub.SendMessageDeleted(audienceIDs, msg, DeleteForEveryone)
+		return nil
+
+	default:
+		return errors.New("scope must be me or everyone")
+	}
+}
+
+// React adds or removes userID's emoji reaction on a message and broadcasts
+// the change to the conversation.
+func (s *ChatService) React(userID, messageID int64, emoji, action string) error {
+	if !isEmoji(emoji) {
+		return errors.New("invalid emoji")
+	}
+
+	msg, err := s.accessibleMessage(userID, messageID)
+	if err != nil {
+		return err
 	}
-	if err := s.msgRepo.Save(msg); err != nil {
+	if msg.RoomID == 0 {
+		peerID := msg.ReceiverID
+		if peerID == userID {
+			peerID = msg.SenderID
+		}
+		blocked, err := s.blocksRepo.IsBlockedEither(userID, peerID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrBlocked
+		}
+	}
+
+	var changed bool
+	switch action {
+	case "", ReactionAdd:
+		action = ReactionAdd
+		changed, err = s.msgRepo.AddReaction(messageID, userID, emoji, time.Now().Unix())
+	case ReactionRemove:
+		changed, err = s.msgRepo.RemoveReaction(messageID, userID, emoji)
+	default:
+		return errors.New("action must be add or remove")
+	}
+	if err != nil || !changed {
 		return err
 	}
 
-	return s.hub.SendMessage(msg)
+	participantIDs, err := s.participants(msg)
+	if err != nil {
+		return err
+	}
+	// In rooms, members who blocked the reacting user or were blocked by
+	// them do not hear about the reaction.
+	blockedIDs, err := s.blocksRepo.GetBlockedPeers(userID)
+	if err != nil {
+		return err
+	}
+	s.hub.SendReaction(without(participantIDs, blockedIDs), msg, userID, emoji, action)
+	return nil
+}
+
+// isEmoji accepts a single emoji or emoji sequence: symbols (So, Sk) joined
+// by ZWJ and followed by variation selectors, skin tones or tag characters,
+// plus keycaps such as "1️⃣". Letters, digits outside keycaps, punctuation
+// and whitespace are rejected, so reactions cannot carry words.
+func isEmoji(s string) bool {
+	if s == "" || !utf8.ValidString(s) || utf8.RuneCountInString(s) > maxEmojiRunes {
+		return false
+	}
+
+	keycap := strings.ContainsRune(s, emojiKeycap)
+	hasSymbol := false
+	for _, r := range s {
+		switch {
+		case r > unicode.MaxASCII && unicode.Is(unicode.So, r):
+			hasSymbol = true
+		case r > unicode.MaxASCII && unicode.Is(unicode.Sk, r):
+		case r == emojiZWJ, r == emojiKeycap,
+			r >= 0xFE00 && r <= 0xFE0F,   // variation selectors
+			r >= 0xE0020 && r <= 0xE007F: // tags, as in subdivision flags
+		case r == 0x203C, r == 0x2049, r >= 0x2194 && r <= 0x2199:
+			// ‼ ⁉ and arrows are emoji outside the So category.
+			hasSymbol = true
+		case keycap && (r == '#' || r == '*' || (r >= '0' && r <= '9')):
+			hasSymbol = true
+		default:
+			return false
+		}
+	}
+	return hasSymbol
+}
+
+// attachReply validates that replyToID belongs to the same conversation as
+// msg and fills in its preview.
+func attachReply(msgRepo websocket.MessageRepository, msg *dto.Message, replyToID int64) error {
+	if replyToID == 0 {
+		return nil
+	}
+
+	quoted, err := msgRepo.Get(replyToID)
+	if errors.Is(err, websocket.ErrMessageNotFound) {
+		return errors.New("reply_to message not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	sameConversation := quoted.RoomID == msg.RoomID
+	if msg.RoomID == 0 {
+		sameConversation = sameConversation &&
+			((quoted.SenderID == msg.SenderID && quoted.ReceiverID == msg.ReceiverID) ||
+				(quoted.SenderID == msg.ReceiverID && quoted.ReceiverID == msg.SenderID))
+	}
+	if !sameConversation {
+		return errors.New("reply_to message is not part of this conversation")
+	}
+
+	msg.ReplyToID = quoted.ID
+	msg.ReplyTo = &dto.MessagePreview{
+		ID:       quoted.ID,
+		SenderID: quoted.SenderID,
+		Text:     truncateRunes(quoted.Text, replyPreviewRunes),
+	}
+	return nil
+}
+
+// attachFiles records which uploaded attachments msg should claim when it
+// is saved.
+func attachFiles(msg *dto.Message, attachmentIDs []int64) error {
+	seen := make(map[int64]bool, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		if id <= 0 {
+			return errors.New("invalid attachment id")
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		msg.AttachmentIDs = append(msg.AttachmentIDs, id)
+	}
+
+	if len(msg.AttachmentIDs) > maxAttachmentsPerMessage {
+		return errors.New("too many attachments")
+	}
+	return nil
+}
+
+// setClientMsgID validates the optional client-chosen ID of msg.
+func setClientMsgID(msg *dto.Message, clientMsgID string) error {
+	if len(clientMsgID) > maxClientMsgIDLength {
+		return errors.New("client_msg_id too long")
+	}
+	for _, r := range clientMsgID {
+		if r < 0x21 || r > 0x7e {
+			return errors.New("client_msg_id must be printable ASCII")
+		}
+	}
+	msg.ClientMsgID = clientMsgID
+	return nil
 }
 
+// messageNotification alerts about msg with a preview of its text.
+func messageNotification(kind string, msg *dto.Message) *dto.Notification {
+	return &dto.Notification{
+		Kind:      kind,
+		SenderID:  msg.SenderID,
+		RoomID:    msg.RoomID,
+		MessageID: msg.ID,
+		Text:      truncateRunes(msg.Text, replyPreviewRunes),
+		CreatedAt: msg.CreatedAt,
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// accessibleMessage loads a message userID is allowed to see.
+func (s *ChatService) accessibleMessage(userID, messageID int64) (*dto.Message, error) {
+	msg, err := s.msgRepo.Get(messageID)
+	if err != nil {
+		return nil, err
+	}
 
+	participantIDs, err := s.participants(msg)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range participantIDs {
+		if id == userID {
+			return msg, nil
+		}
+	}
+	return nil, ErrNotParticipant
+}
+
+// audience lists who is told about an edit or delete of msg: those who
+// received it, and the sender. A message held in a message request, or
+// sent across a block, was never delivered, so only the sender hears
+// about it.
+func (s *ChatService) audience(msg *dto.Message) ([]int64, error) {
+	if msg.RoomID == 0 {
+		if msg.IsRequest {
+			return []int64{msg.SenderID}, nil
+		}
+		blocked, err := s.blocksRepo.IsBlockedEither(msg.SenderID, msg.ReceiverID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return []int64{msg.SenderID}, nil
+		}
+		return []int64{msg.SenderID, msg.ReceiverID}, nil
+	}
+
+	participantIDs, err := s.participants(msg)
+	if err != nil {
+		return nil, err
+	}
+	blockedIDs, err := s.blocksRepo.GetBlockedPeers(msg.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	return without(participantIDs, blockedIDs), nil
+}
+
+// participants lists who can see msg: both ends of a direct message, or
+// the current members of its room.
+func (s *ChatService) participants(msg *dto.Message) ([]int64, error) {
+	if msg.RoomID == 0 {
+		return []int64{msg.SenderID, msg.ReceiverID}, nil
+	}
+
+	members, err := s.roomRepo.GetMembers(msg.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	return userIDs(members), nil
+}
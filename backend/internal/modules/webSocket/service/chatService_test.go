@@ -0,0 +1,193 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/config"
+	blocksRepo "lilyChat/internal/modules/blocks/repository"
+	dto "lilyChat/internal/modules/dto"
+	websocket "lilyChat/internal/modules/webSocket"
+	"lilyChat/internal/modules/webSocket/hub"
+)
+
+// recordingBroker captures what the hub publishes, which is every event
+// together with the users it is addressed to.
+type recordingBroker struct {
+	mu     sync.Mutex
+	events []hub.BrokerEvent
+}
+
+func (b *recordingBroker) Publish(event hub.BrokerEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+	return nil
+}
+
+func (b *recordingBroker) Subscribe(func(hub.BrokerEvent)) error { return nil }
+func (b *recordingBroker) Close() error                          { return nil }
+
+// recipients returns the users the events of the given type went to.
+func (b *recordingBroker) recipients(eventType string) []int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []int64
+	for _, event := range b.events {
+		if event.Data["type"] == eventType {
+			ids = append(ids, event.UserIDs...)
+		}
+	}
+	return ids
+}
+
+// fakeMessageRepo serves a single message. Methods the tests do not use
+// panic through the nil embedded interface.
+type fakeMessageRepo struct {
+	websocket.MessageRepository
+	msg *dto.Message
+}
+
+func (r *fakeMessageRepo) Get(messageID int64) (*dto.Message, error) {
+	if r.msg == nil || r.msg.ID != messageID {
+		return nil, websocket.ErrMessageNotFound
+	}
+	copied := *r.msg
+	return &copied, nil
+}
+
+func (r *fakeMessageRepo) UpdateText(messageID int64, text string, editedAt int64) (*dto.Message, error) {
+	r.msg.Text = text
+	r.msg.EditedAt = editedAt
+	return r.Get(messageID)
+}
+
+func (r *fakeMessageRepo) DeleteForEveryone(messageID, at int64) error {
+	r.msg.Text = ""
+	return nil
+}
+
+type fakeBlocksRepo struct {
+	blocksRepo.BlocksRepositorier
+	blocked bool
+}
+
+func (r *fakeBlocksRepo) IsBlockedEither(user1ID, user2ID int64) (bool, error) {
+	return r.blocked, nil
+}
+
+func (r *fakeBlocksRepo) GetBlockedPeers(userID int64) ([]int64, error) {
+	return nil, nil
+}
+
+func newTestChatService(t *testing.T, msg *dto.Message, blocked bool) (*ChatService, *recordingBroker) {
+	t.Helper()
+
+	broker := &recordingBroker{}
+	wsHub := hub.NewHub()
+	if err := wsHub.UseBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	comps := &components.Components{
+		Conf: config.Config{Chat: config.ChatConfig{
+			MaxMessageLength: 4000,
+			DeleteWindow:     time.Hour,
+		}},
+		WSHub: wsHub,
+	}
+	msgs := &fakeMessageRepo{msg: msg}
+	blocks := &fakeBlocksRepo{blocked: blocked}
+	return NewChatService(msgs, nil, nil, nil, blocks, nil, nil, comps), broker
+}
+
+func TestChangesReachOnlyThoseWhoReceivedTheMessage(t *testing.T) {
+	const senderID, receiverID = 1, 2
+
+	tests := []struct {
+		name      string
+		isRequest bool
+		blocked   bool
+		want      []int64
+	}{
+		{"delivered", false, false, []int64{senderID, receiverID}},
+		{"held in a message request", true, false, []int64{senderID}},
+		{"sent across a block", false, true, []int64{senderID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/edit", func(t *testing.T) {
+			msg := &dto.Message{ID: 10, SenderID: senderID, ReceiverID: receiverID, Text: "hi",
+				CreatedAt: time.Now().Unix(), IsRequest: tt.isRequest}
+			s, broker := newTestChatService(t, msg, tt.blocked)
+
+			updated, err := s.EditMessage(senderID, msg.ID, "hello")
+			if err != nil {
+				t.Fatalf("EditMessage: %v", err)
+			}
+			if updated.Text != "hello" {
+				t.Errorf("text = %q, want %q", updated.Text, "hello")
+			}
+			if got := broker.recipients("message_updated"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message_updated went to %v, want %v", got, tt.want)
+			}
+		})
+
+		t.Run(tt.name+"/delete", func(t *testing.T) {
+			msg := &dto.Message{ID: 10, SenderID: senderID, ReceiverID: receiverID, Text: "hi",
+				CreatedAt: time.Now().Unix(), IsRequest: tt.isRequest}
+			s, broker := newTestChatService(t, msg, tt.blocked)
+
+			if err := s.DeleteMessage(senderID, msg.ID, DeleteForEveryone); err != nil {
+				t.Fatalf("DeleteMessage: %v", err)
+			}
+			if got := broker.recipients("message_deleted"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message_deleted went to %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		want  bool
+	}{
+		{"single emoji", "👍", true},
+		{"symbol with variation selector", "❤️", true},
+		{"skin tone", "👍🏽", true},
+		{"zwj sequence", "👩‍💻", true},
+		{"family", "👨‍👩‍👧‍👦", true},
+		{"flag", "🇺🇦", true},
+		{"subdivision flag", "🏴󠁧󠁢󠁳󠁣󠁴󠁿", true},
+		{"keycap", "1️⃣", true},
+		{"keycap hash", "#️⃣", true},
+		{"double exclamation", "‼️", true},
+		{"arrow", "↔️", true},
+
+		{"empty", "", false},
+		{"letter", "a", false},
+		{"word", "lol", false},
+		{"digit without keycap", "1", false},
+		{"hash without keycap", "#", false},
+		{"emoji followed by text", "👍ok", false},
+		{"emoji with space", "👍 ", false},
+		{"punctuation", "!", false},
+		{"modifier only", "🏽", false},
+		{"zwj only", "‍", false},
+		{"too long", strings.Repeat("👍", maxEmojiRunes+1), false},
+		{"invalid utf-8", "\xff", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEmoji(tt.emoji); got != tt.want {
+				t.Errorf("isEmoji(%q) = %v, want %v", tt.emoji, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"lilyChat/internal/infrastructure/components"
+	"lilyChat/internal/infrastructure/config"
+	blocksRepo "lilyChat/internal/modules/blocks/repository"
+	contactsRepo "lilyChat/internal/modules/contacts/repository"
+	dto "lilyChat/internal/modules/dto"
+	notifications "lilyChat/internal/modules/notifications/service"
+	usersRepo "lilyChat/internal/modules/users/repository"
+	websocket "lilyChat/internal/modules/webSocket"
+	"lilyChat/internal/modules/webSocket/hub"
+)
+
+const maxRoomNameLength = 100
+
+const (
+	RoomMemberAdded   = "added"
+	RoomMemberRemoved = "removed"
+)
+
+var (
+	ErrNotRoomMember = errors.New("not a member of this room")
+	ErrNotRoomOwner  = errors.New("only the room owner can do this")
+	ErrUserNotFound  = errors.New("user not found")
+	// ErrCannotAddMember is returned for a user who blocked the one adding
+	// them, was blocked by them, or does not accept messages from them.
+	ErrCannotAddMember = errors.New("this user cannot be added to the room")
+)
+
+type RoomServicer interface {
+	CreateRoom(ownerID int64, name string, memberIDs []int64) (*dto.Room, error)
+	GetRoom(userID, roomID int64) (*dto.Room, error)
+	ListRooms(userID int64) ([]*dto.Room, error)
+	AddMember(requesterID, roomID, userID int64) error
+	RemoveMember(requesterID, roomID, userID int64) error
+	SendMessage(senderID int64, req dto.SendMessageRequest) (*dto.Message, error)
+	GetHistory(userID, roomID int64, query dto.HistoryQuery) ([]*dto.Message, error)
+}
+
+type RoomService struct {
+	roomRepo      websocket.RoomRepository
+	msgRepo       websocket.MessageRepository
+	usersRepo     usersRepo.UsersRepositorier
+	blocksRepo    blocksRepo.BlocksRepositorier
+	contactsRepo  contactsRepo.ContactsRepositorier
+	notifications notifications.NotificationsServicer
+	hub           *hub.Hub
+	cfg           config.ChatConfig
+}
+
+func NewRoomService(roomRepo websocket.RoomRepository, msgRepo websocket.MessageRepository, usersRepo usersRepo.UsersRepositorier, blocksRepo blocksRepo.BlocksRepositorier, contactsRepo contactsRepo.ContactsRepositorier, notifications notifications.NotificationsServicer, components *components.Components) *RoomService {
+	return &RoomService{
+		roomRepo:      roomRepo,
+		msgRepo:       msgRepo,
+		usersRepo:     usersRepo,
+		blocksRepo:    blocksRepo,
+		contactsRepo:  contactsRepo,
+		notifications: notifications,
+		hub:           components.WSHub,
+		cfg:           components.Conf.Chat,
+	}
+}
+
+func (s *RoomService) CreateRoom(ownerID int64, name string, memberIDs []int64) (*dto.Room, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("room name is required")
+	}
+	if len([]rune(name)) > maxRoomNameLength {
+		return nil, errors.New("room name too long")
+	}
+	for _, memberID := range memberIDs {
+		if memberID == ownerID {
+			continue
+		}
+		if err := s.checkCanAdd(ownerID, memberID); err != nil {
+			return nil, err
+		}
+	}
+
+	room := &dto.Room{
+		Name:      name,
+		OwnerID:   ownerID,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := s.roomRepo.Create(room, memberIDs); err != nil {
+		return nil, err
+	}
+
+	members, err := s.roomRepo.GetMembers(room.ID)
+	if err != nil {
+		return nil, err
+	}
+	room.Members = members
+
+	s.hub.SendRoomCreated(userIDs(members), room)
+	return room, nil
+}
+
+// GetRoom returns the room with its member list; only members may see it.
+func (s *RoomService) GetRoom(userID, roomID int64) (*dto.Room, error) {
+	if err := s.requireMember(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	room, err := s.roomRepo.Get(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	room.Members, err = s.roomRepo.GetMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func (s *RoomService) ListRooms(userID int64) ([]*dto.Room, error) {
+	return s.roomRepo.ListForUser(userID)
+}
+
+// AddMember lets the room owner add userID to the room.
+func (s *RoomService) AddMember(requesterID, roomID, userID int64) error {
+	room, err := s.roomRepo.Get(roomID)
+	if err != nil {
+		return err
+	}
+	if room.OwnerID != requesterID {
+		return ErrNotRoomOwner
+	}
+	if err := s.checkCanAdd(requesterID, userID); err != nil {
+		return err
+	}
+
+	if err := s.roomRepo.AddMember(roomID, userID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	memberIDs, err := s.memberIDs(roomID)
+	if err != nil {
+		return err
+	}
+	s.hub.SendRoomMember(memberIDs, roomID, userID, RoomMemberAdded)
+	return nil
+}
+
+// RemoveMember lets the owner remove anyone but themselves, and any other
+// member leave the room. To non-members the room does not exist.
+func (s *RoomService) RemoveMember(requesterID, roomID, userID int64) error {
+	if err := s.requireMember(roomID, requesterID); err != nil {
+		if errors.Is(err, ErrNotRoomMember) {
+			return websocket.ErrRoomNotFound
+		}
+		return err
+	}
+	if userID != requesterID {
+		if err := s.requireMember(roomID, userID); err != nil {
+			return err
+		}
+	}
+
+	room, err := s.roomRepo.Get(roomID)
+	if err != nil {
+		return err
+	}
+	if userID == room.OwnerID {
+		return errors.New("the room owner cannot leave the room")
+	}
+	if requesterID != room.OwnerID && requesterID != userID {
+		return ErrNotRoomOwner
+	}
+
+	memberIDs, err := s.memberIDs(roomID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roomRepo.RemoveMember(roomID, userID); err != nil {
+		return err
+	}
+
+	// memberIDs was taken before the removal so the removed user hears it too.
+	s.hub.SendRoomMember(memberIDs, roomID, userID, RoomMemberRemoved)
+	return nil
+}
+
+func (s *RoomService) SendMessage(senderID int64, req dto.SendMessageRequest) (*dto.Message, error) {
+	roomID := req.RoomID
+	if err := s.requireMember(roomID, senderID); err != nil {
+		return nil, err
+	}
+
+	text, err := messageText(req.Text, s.cfg.MaxMessageLength, len(req.AttachmentIDs) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &dto.Message{
+		SenderID:  senderID,
+		RoomID:    roomID,
+		Text:      text,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := attachReply(s.msgRepo, msg, req.ReplyTo); err != nil {
+		return nil, err
+	}
+	if err := attachFiles(msg, req.AttachmentIDs); err != nil {
+		return nil, err
+	}
+	if err := setClientMsgID(msg, req.ClientMsgID); err != nil {
+		return nil, err
+	}
+	err = s.msgRepo.Save(msg)
+	if errors.Is(err, websocket.ErrDuplicateMessage) {
+		if msg.RoomID != roomID {
+			return nil, ErrClientMsgIDConflict
+		}
+		s.hub.ResendMessage(senderID, msg)
+		return msg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs, err := s.memberIDs(roomID)
+	if err != nil {
+		return nil, err
+	}
+	// Members who blocked the sender, or were blocked by them, do not get
+	// the message.
+	blockedIDs, err := s.blocksRepo.GetBlockedPeers(senderID)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs = without(memberIDs, blockedIDs)
+	s.hub.SendRoomMessage(memberIDs, msg)
+	s.notifications.Notify(without(memberIDs, []int64{senderID}), messageNotification(dto.NotificationMessage, msg))
+	return msg, nil
+}
+
+func (s *RoomService) GetHistory(userID, roomID int64, query dto.HistoryQuery) ([]*dto.Message, error) {
+	if err := s.requireMember(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.msgRepo.GetRoomHistory(roomID, userID, clampHistoryQuery(query))
+}
+
+// checkCanAdd makes sure userID exists and applies the same rules to being
+// put in a room as to being messaged directly: no one may add a user across
+// a block, a user whose privacy is nobody may not be added at all, and one
+// whose privacy is contacts only by a contact.
+func (s *RoomService) checkCanAdd(requesterID, userID int64) error {
+	if userID <= 0 {
+		return ErrUserNotFound
+	}
+	exists, err := s.usersRepo.Exists(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	blocked, err := s.blocksRepo.IsBlockedEither(requesterID, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrCannotAddMember
+	}
+
+	privacy, err := s.usersRepo.GetPrivacy(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+	switch privacy {
+	case dto.PrivacyEveryone:
+		return nil
+	case dto.PrivacyContacts:
+		contacts, err := s.contactsRepo.AreContacts(requesterID, userID)
+		if err != nil {
+			return err
+		}
+		if contacts {
+			return nil
+		}
+	}
+	return ErrCannotAddMember
+}
+
+func (s *RoomService) requireMember(roomID, userID int64) error {
+	isMember, err := s.roomRepo.IsMember(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrNotRoomMember
+	}
+	return nil
+}
+
+func (s *RoomService) memberIDs(roomID int64) ([]int64, error) {
+	members, err := s.roomRepo.GetMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return userIDs(members), nil
+}
+
+func userIDs(users []*dto.PublicUser) []int64 {
+	ids := make([]int64, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrEmptyMessage     = errors.New("message text is required")
+	ErrMessageTooLong   = errors.New("message text too long")
+	ErrInvalidEncoding  = errors.New("message text must be valid UTF-8")
+	ErrReceiverNotFound = errors.New("receiver not found")
+	ErrSelfMessage      = errors.New("cannot send a message to yourself")
+)
+
+// maxBlankLines is how many empty lines in a row survive normalization.
+const maxBlankLines = 2
+
+// normalizeText checks that text is valid UTF-8 and at most maxRunes long
+// once normalized: line endings become \n, control characters other than
+// newlines and tabs are dropped, trailing spaces are cut from every line,
+// runs of blank lines are shortened and the whole text is trimmed.
+func normalizeText(text string, maxRunes int) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", ErrInvalidEncoding
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, text)
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			blank++
+			if blank > maxBlankLines {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		kept = append(kept, line)
+	}
+	text = strings.TrimSpace(strings.Join(kept, "\n"))
+
+	if utf8.RuneCountInString(text) > maxRunes {
+		return "", ErrMessageTooLong
+	}
+	return text, nil
+}
+
+// messageText normalizes the text of a new message, which may only be
+// empty when files are attached.
+func messageText(text string, maxRunes int, hasAttachments bool) (string, error) {
+	text, err := normalizeText(text, maxRunes)
+	if err != nil {
+		return "", err
+	}
+	if text == "" && !hasAttachments {
+		return "", ErrEmptyMessage
+	}
+	return text, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		max     int
+		want    string
+		wantErr error
+	}{
+		{"plain text", "hello", 10, "hello", nil},
+		{"trims surrounding space", "  hello \n", 10, "hello", nil},
+		{"crlf becomes lf", "a\r\nb", 10, "a\nb", nil},
+		{"lone cr becomes lf", "a\rb", 10, "a\nb", nil},
+		{"keeps tabs", "a\tb", 10, "a\tb", nil},
+		{"drops control characters", "a\x00b\x07c", 10, "abc", nil},
+		{"cuts trailing spaces on every line", "a  \nb\t\nc", 10, "a\nb\nc", nil},
+		{"keeps two blank lines", "a\n\n\nb", 10, "a\n\n\nb", nil},
+		{"shortens longer runs of blank lines", "a\n\n\n\n\n\nb", 10, "a\n\n\nb", nil},
+		{"whitespace only is empty", " \n\t\r\n ", 10, "", nil},
+		{"counts runes, not bytes", "привет", 6, "привет", nil},
+		{"limit applies after normalization", "hello     ", 5, "hello", nil},
+		{"too long", "abcdef", 5, "", ErrMessageTooLong},
+		{"too many emoji", strings.Repeat("👍", 6), 5, "", ErrMessageTooLong},
+		{"invalid utf-8", "a\xffb", 10, "", ErrInvalidEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeText(tt.text, tt.max)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		hasAttachments bool
+		want           string
+		wantErr        error
+	}{
+		{"text", " hi ", false, "hi", nil},
+		{"empty without attachments", "", false, "", ErrEmptyMessage},
+		{"blank without attachments", " \n ", false, "", ErrEmptyMessage},
+		{"empty with attachments", "", true, "", nil},
+		{"too long with attachments", "abcdef", true, "", ErrMessageTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := messageText(tt.text, 5, tt.hasAttachments)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("messageText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
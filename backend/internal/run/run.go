@@ -2,9 +2,11 @@ package run
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"net/http"
 
+	"lilyChat/internal/infrastructure/broker"
 	"lilyChat/internal/infrastructure/db"
 	"lilyChat/internal/infrastructure/components"
 	"lilyChat/internal/infrastructure/config"
@@ -21,6 +23,8 @@ type AppConf struct {
 	Controller *modules.Controller
 	Components *components.Components
 	HTTPServer server.Server
+	// DebugServer listens on cfg.Server.DebugAddr for internal tooling.
+	DebugServer server.Server
 }
 
 func Run() *AppConf {
@@ -38,6 +42,7 @@ func Run() *AppConf {
 
 	sqlDB := db.InitDB(cfg.PostgresDSN)
 	pgRepo := db.NewPostgresRepo(sqlDB)
+	initBroker(cfg, sqlDB, comps)
 
 	repo := modules.NewRepository(sqlDB, comps)
 	service := modules.NewServices(*repo, comps)
@@ -50,6 +55,10 @@ func Run() *AppConf {
 	}
 
 	appServer := server.NewHttpServer(cfg.Server, httpSrv)
+	debugServer := server.NewHttpServer(cfg.Server, &http.Server{
+		Addr:    cfg.Server.DebugAddr,
+		Handler: routes.NewDebugRouter(),
+	})
 
 	return &AppConf{
 		Cfg:        cfg,
@@ -58,10 +67,34 @@ func Run() *AppConf {
 		Controller: controller,
 		Components: comps,
 		HTTPServer: appServer,
+		DebugServer: debugServer,
+	}
+}
+
+// initBroker connects the WebSocket hub to other server instances, for
+// events and online state, when a broker driver other than "local" is
+// configured.
+func initBroker(cfg *config.Config, sqlDB *sql.DB, comps *components.Components) {
+	switch cfg.Broker.Driver {
+	case "local":
+	case "postgres":
+		pgBroker := broker.NewPostgresBroker(sqlDB, cfg.PostgresDSN, cfg.Broker.Channel)
+		if err := comps.WSHub.UseBroker(pgBroker); err != nil {
+			log.Fatalf("[Broker] cannot subscribe: %v", err)
+		}
+		comps.WSHub.UsePresence(broker.NewPostgresPresence(sqlDB))
+	default:
+		log.Fatalf("[Broker] unknown driver %q", cfg.Broker.Driver)
 	}
 }
 
 func (a *AppConf) Start(ctx context.Context) {
+	go func() {
+		if err := a.DebugServer.Serve(ctx); err != nil {
+			log.Println("Debug server error:", err)
+		}
+	}()
+
 	if err := a.HTTPServer.Serve(ctx); err != nil {
 		log.Fatal("Server error:", err)
 	}